@@ -2,11 +2,16 @@ package api
 
 import (
 	"context"
+	"downite/completion"
 	"downite/db"
 	"downite/download/protocol/direct"
 	"downite/download/protocol/torr"
+	"downite/extract"
 	"downite/handlers"
+	"downite/history"
+	"downite/scheduler"
 	"downite/settings"
+	"downite/types"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -85,25 +90,73 @@ func ApiInit(options *ApiOptions) *API {
 			fmt.Printf("Cannot connect to db : %s", err)
 		}
 
-		// initilize torrent engine
+		// initilize settings
 		settingsSystem, err := settings.InitilizeSettingsSystem(db, nil)
+		if err != nil {
+			panic(fmt.Errorf("cannot initilize settings : %s", err))
+		}
 		// initilize torrent engine
-		torrentEngine, err := InitTorrentEngine(db)
+		torrentEngine, err := InitTorrentEngine(db, settingsSystem.Settings)
 		// initilize download client
-		downloadEngine, err := InitDownloadEngine(db)
+		downloadEngine, err := InitDownloadEngine(db, settingsSystem.Settings)
 		// register download routes
 		AddDownloadRoutes(handlers.DownloadHandler{
-			Db:     db,
-			Engine: downloadEngine,
+			Db:             db,
+			Engine:         downloadEngine,
+			TorrentEngine:  torrentEngine,
+			SettingsSystem: settingsSystem,
 		}, api.humaApi)
 		// register torrent routes
 		AddTorrentRoutes(handlers.TorrentHandler{
 			Db:     db,
 			Engine: torrentEngine,
 		}, api.humaApi)
+		// initilize completion actions
+		completionRunner := completion.CreateActionRunner(db, downloadEngine, settingsSystem.Settings.Completion)
+		err = completionRunner.InitRules()
+		if err != nil {
+			fmt.Printf("Cannot initilize completion rules : %s", err)
+		}
+		// archives are extracted before completion rules run
+		archiveExtractor := extract.CreateExtractor(db, settingsSystem.Settings.Extraction, completionRunner.RunRules)
+		err = archiveExtractor.InitExtractions()
+		if err != nil {
+			fmt.Printf("Cannot initilize extractions : %s", err)
+		}
+		downloadEngine.AddCompletionListener(archiveExtractor.Consume)
+		if torrentEngine != nil {
+			torrentEngine.AddCompletionListener(archiveExtractor.Consume)
+		}
+		AddCompletionRoutes(handlers.CompletionHandler{
+			Runner: completionRunner,
+		}, api.humaApi)
+		AddExtractionRoutes(handlers.ExtractionHandler{
+			Extractor: archiveExtractor,
+		}, api.humaApi)
+		AddGrabberRoutes(handlers.GrabberHandler{
+			Engine: downloadEngine,
+		}, api.humaApi)
 		AddSystemRoutes(handlers.SystemHandler{}, api.humaApi)
 		AddSettingsRoutes(handlers.SettingsHandler{
 			SettingsSystem: settingsSystem,
+			DownloadEngine: downloadEngine,
+			Extractor:      archiveExtractor,
+		}, api.humaApi)
+		// initilize scheduler
+		downloadScheduler := scheduler.CreateScheduler(db, downloadEngine, torrentEngine)
+		err = downloadScheduler.InitSchedule()
+		if err != nil {
+			fmt.Printf("Cannot initilize scheduler : %s", err)
+		}
+		AddScheduleRoutes(handlers.ScheduleHandler{
+			Scheduler: downloadScheduler,
+		}, api.humaApi)
+
+		// initilize transfer history
+		transferHistory := history.CreateHistory(db, downloadEngine, torrentEngine)
+		transferHistory.InitHistory()
+		AddHistoryRoutes(handlers.HistoryHandler{
+			History: transferHistory,
 		}, api.humaApi)
 
 		api.ExportOpenApi()
@@ -116,6 +169,10 @@ func ApiInit(options *ApiOptions) *API {
 
 		// Tell the CLI how to stop your server.
 		hooks.OnStop(func() {
+			downloadScheduler.Stop()
+			transferHistory.Stop()
+			archiveExtractor.Stop()
+			completionRunner.Stop()
 			errs := torrentEngine.Stop()
 			if len(errs) > 0 {
 				for _, err := range errs {
@@ -146,7 +203,8 @@ func (api *API) Run() {
 	api.Cli.Run()
 }
 
-func InitTorrentEngine(db *db.Database) (*torr.TorrentEngine, error) {
+// InitTorrentEngine creates the torrent engine. downiteSettings can be nil to use the default config
+func InitTorrentEngine(db *db.Database, downiteSettings *types.DowniteSettings) (*torr.TorrentEngine, error) {
 	// initilize torrent engine
 	pieceCompletionDir := "./tmp"
 	defaultTorrentsDir := "./tmp/torrents"
@@ -154,6 +212,13 @@ func InitTorrentEngine(db *db.Database) (*torr.TorrentEngine, error) {
 		PieceCompletionDbPath: pieceCompletionDir,
 		DownloadPath:          defaultTorrentsDir,
 	}
+	if downiteSettings != nil {
+		proxyUrl, err := downiteSettings.Proxy.Url()
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy settings : %s", err)
+		}
+		torrentEngineConfig.Proxy = proxyUrl
+	}
 	torrentEngine, err := torr.CreateTorrentEngine(torrentEngineConfig, db)
 	if err != nil {
 		return nil, fmt.Errorf("cannot create torrent engine : %s", err)
@@ -166,11 +231,26 @@ func InitTorrentEngine(db *db.Database) (*torr.TorrentEngine, error) {
 	return torrentEngine, nil
 }
 
-func InitDownloadEngine(db *db.Database) (*direct.DirectDownloadEngine, error) {
+// InitDownloadEngine creates the download engine. downiteSettings can be nil to use the default config
+func InitDownloadEngine(db *db.Database, downiteSettings *types.DowniteSettings) (*direct.DirectDownloadEngine, error) {
 	defaultClientConfig, err := direct.NewClientDefaultConfig()
 	if err != nil {
 		return nil, fmt.Errorf("Cannot get default config : %s", err)
 	}
+	if downiteSettings != nil {
+		defaultClientConfig.MaxConcurrentDownloads = downiteSettings.MaxConcurrentDownloads
+		defaultClientConfig.SpeedLimit = downiteSettings.SpeedLimit
+		proxyUrl, err := downiteSettings.Proxy.Url()
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy settings : %s", err)
+		}
+		defaultClientConfig.Proxy = proxyUrl
+		defaultClientConfig.Hosts = downiteSettings.Hosts
+		defaultClientConfig.RetryCount = downiteSettings.Retry.RetryCount
+		defaultClientConfig.RetryDelay = time.Duration(downiteSettings.Retry.RetryDelay) * time.Millisecond
+		defaultClientConfig.MaxRetryDelay = time.Duration(downiteSettings.Retry.MaxRetryDelay) * time.Millisecond
+		defaultClientConfig.RestartChangedDownloads = downiteSettings.RestartChangedDownloads
+	}
 	// initilize download client
 	downloadClient, err := direct.CreateDownloadClient(defaultClientConfig, db)
 	if err != nil {
@@ -304,6 +384,42 @@ func AddDownloadRoutes(handler handlers.DownloadHandler, humaApi huma.API) {
 		Path:        "/download/meta",
 		Summary:     "Get meta data of download",
 	}, handler.GetDownloadMeta)
+	huma.Register(humaApi, huma.Operation{
+		OperationID: "get-url-formats",
+		Method:      http.MethodPost,
+		Path:        "/download/formats",
+		Summary:     "Get formats of a page like a video page",
+	}, handler.GetUrlFormats)
+	huma.Register(humaApi, huma.Operation{
+		OperationID: "get-playlist",
+		Method:      http.MethodPost,
+		Path:        "/download/playlist",
+		Summary:     "Get entries of a playlist or channel",
+	}, handler.GetPlaylist)
+	huma.Register(humaApi, huma.Operation{
+		OperationID: "download-playlist",
+		Method:      http.MethodPost,
+		Path:        "/download/playlist/add",
+		Summary:     "Download entries of a playlist as a batch",
+	}, handler.DownloadPlaylist)
+	huma.Register(humaApi, huma.Operation{
+		OperationID: "get-download-batches",
+		Method:      http.MethodGet,
+		Path:        "/download/batch",
+		Summary:     "Get download batches",
+	}, handler.GetDownloadBatches)
+	huma.Register(humaApi, huma.Operation{
+		OperationID: "import-urls",
+		Method:      http.MethodPost,
+		Path:        "/download/batch/import",
+		Summary:     "Download urls of a text as a batch",
+	}, handler.ImportUrls)
+	huma.Register(humaApi, huma.Operation{
+		OperationID: "import-url-file",
+		Method:      http.MethodPost,
+		Path:        "/download/batch/import/file",
+		Summary:     "Download urls of a text file as a batch",
+	}, handler.ImportUrlFile)
 	huma.Register(humaApi, huma.Operation{
 		OperationID: "download",
 		Method:      http.MethodPost,
@@ -334,6 +450,12 @@ func AddDownloadRoutes(handler handlers.DownloadHandler, humaApi huma.API) {
 		Path:        "/download/resume",
 		Summary:     "Resume download",
 	}, handler.ResumeDownload)
+	huma.Register(humaApi, huma.Operation{
+		OperationID: "restart-download",
+		Method:      http.MethodPost,
+		Path:        "/download/restart",
+		Summary:     "Restart download from the beginning",
+	}, handler.RestartDownload)
 	huma.Register(humaApi, huma.Operation{
 		OperationID: "remove-download",
 		Method:      http.MethodPost,
@@ -358,6 +480,36 @@ func AddDownloadRoutes(handler handlers.DownloadHandler, humaApi huma.API) {
 		Path:        "/download/new-file-name",
 		Summary:     "Get new duplicate name",
 	}, handler.GetNewFileNameForPath)
+	huma.Register(humaApi, huma.Operation{
+		OperationID: "get-global-speed-limit",
+		Method:      http.MethodGet,
+		Path:        "/download/global-speed-limit",
+		Summary:     "Get global speed limit of downloads",
+	}, handler.GetGlobalSpeedLimit)
+	huma.Register(humaApi, huma.Operation{
+		OperationID: "set-global-speed-limit",
+		Method:      http.MethodPost,
+		Path:        "/download/global-speed-limit",
+		Summary:     "Set global speed limit of downloads",
+	}, handler.SetGlobalSpeedLimit)
+	huma.Register(humaApi, huma.Operation{
+		OperationID: "set-download-speed-limit",
+		Method:      http.MethodPost,
+		Path:        "/download/speed-limit",
+		Summary:     "Set speed limit of downloads",
+	}, handler.SetDownloadSpeedLimit)
+	huma.Register(humaApi, huma.Operation{
+		OperationID: "verify-download",
+		Method:      http.MethodPost,
+		Path:        "/download/verify",
+		Summary:     "Verify checksum of completed downloads",
+	}, handler.VerifyDownload)
+	huma.Register(humaApi, huma.Operation{
+		OperationID: "import-metalink",
+		Method:      http.MethodPost,
+		Path:        "/download/metalink",
+		Summary:     "Import downloads from metalink file",
+	}, handler.ImportMetalink)
 }
 
 func AddSettingsRoutes(handler handlers.SettingsHandler, humaApi huma.API) {
@@ -373,6 +525,78 @@ func AddSettingsRoutes(handler handlers.SettingsHandler, humaApi huma.API) {
 		Path:        "/settings/add-save-path",
 		Summary:     "Add Save Path",
 	}, handler.AddSavePath)
+	huma.Register(humaApi, huma.Operation{
+		OperationID: "get-max-concurrent-downloads",
+		Method:      http.MethodGet,
+		Path:        "/settings/max-concurrent-downloads",
+		Summary:     "Get max concurrent downloads",
+	}, handler.GetMaxConcurrentDownloads)
+	huma.Register(humaApi, huma.Operation{
+		OperationID: "set-max-concurrent-downloads",
+		Method:      http.MethodPost,
+		Path:        "/settings/max-concurrent-downloads",
+		Summary:     "Set max concurrent downloads",
+	}, handler.SetMaxConcurrentDownloads)
+	huma.Register(humaApi, huma.Operation{
+		OperationID: "get-proxy",
+		Method:      http.MethodGet,
+		Path:        "/settings/proxy",
+		Summary:     "Get proxy",
+	}, handler.GetProxy)
+	huma.Register(humaApi, huma.Operation{
+		OperationID: "set-proxy",
+		Method:      http.MethodPost,
+		Path:        "/settings/proxy",
+		Summary:     "Set proxy",
+	}, handler.SetProxy)
+	huma.Register(humaApi, huma.Operation{
+		OperationID: "get-extraction-settings",
+		Method:      http.MethodGet,
+		Path:        "/settings/extraction",
+		Summary:     "Get archive extraction settings",
+	}, handler.GetExtractionSettings)
+	huma.Register(humaApi, huma.Operation{
+		OperationID: "set-extraction-settings",
+		Method:      http.MethodPost,
+		Path:        "/settings/extraction",
+		Summary:     "Set archive extraction settings",
+	}, handler.SetExtractionSettings)
+	huma.Register(humaApi, huma.Operation{
+		OperationID: "get-host-settings",
+		Method:      http.MethodGet,
+		Path:        "/settings/hosts",
+		Summary:     "Get connection limits of hosts",
+	}, handler.GetHostSettings)
+	huma.Register(humaApi, huma.Operation{
+		OperationID: "set-host-settings",
+		Method:      http.MethodPost,
+		Path:        "/settings/hosts",
+		Summary:     "Set connection limits of hosts",
+	}, handler.SetHostSettings)
+	huma.Register(humaApi, huma.Operation{
+		OperationID: "get-retry-settings",
+		Method:      http.MethodGet,
+		Path:        "/settings/retry",
+		Summary:     "Get retry settings of downloads",
+	}, handler.GetRetrySettings)
+	huma.Register(humaApi, huma.Operation{
+		OperationID: "set-retry-settings",
+		Method:      http.MethodPost,
+		Path:        "/settings/retry",
+		Summary:     "Set retry settings of downloads",
+	}, handler.SetRetrySettings)
+	huma.Register(humaApi, huma.Operation{
+		OperationID: "get-restart-changed-downloads",
+		Method:      http.MethodGet,
+		Path:        "/settings/restart-changed-downloads",
+		Summary:     "Get whether downloads whose remote file changed are restarted",
+	}, handler.GetRestartChangedDownloads)
+	huma.Register(humaApi, huma.Operation{
+		OperationID: "set-restart-changed-downloads",
+		Method:      http.MethodPost,
+		Path:        "/settings/restart-changed-downloads",
+		Summary:     "Set whether downloads whose remote file changed are restarted",
+	}, handler.SetRestartChangedDownloads)
 }
 
 // Create a custom middleware handler to disable CORS
@@ -385,3 +609,108 @@ func AddSettingsRoutes(handler handlers.SettingsHandler, humaApi huma.API) {
 // 	// operation handler as well.
 // 	next(ctx)
 // }
+
+func AddScheduleRoutes(handler handlers.ScheduleHandler, humaApi huma.API) {
+	huma.Register(humaApi, huma.Operation{
+		OperationID: "get-scheduled-tasks",
+		Method:      http.MethodGet,
+		Path:        "/schedule/task",
+		Summary:     "Get scheduled tasks",
+	}, handler.GetScheduledTasks)
+	huma.Register(humaApi, huma.Operation{
+		OperationID: "add-scheduled-task",
+		Method:      http.MethodPost,
+		Path:        "/schedule/task",
+		Summary:     "Schedule starting or pausing a download or torrent",
+	}, handler.AddScheduledTask)
+	huma.Register(humaApi, huma.Operation{
+		OperationID: "delete-scheduled-tasks",
+		Method:      http.MethodPost,
+		Path:        "/schedule/task/delete",
+		Summary:     "Delete scheduled tasks",
+	}, handler.DeleteScheduledTasks)
+	huma.Register(humaApi, huma.Operation{
+		OperationID: "get-schedule-windows",
+		Method:      http.MethodGet,
+		Path:        "/schedule/window",
+		Summary:     "Get weekly time windows of download queue",
+	}, handler.GetScheduleWindows)
+	huma.Register(humaApi, huma.Operation{
+		OperationID: "add-schedule-window",
+		Method:      http.MethodPost,
+		Path:        "/schedule/window",
+		Summary:     "Add weekly time window of download queue",
+	}, handler.AddScheduleWindow)
+	huma.Register(humaApi, huma.Operation{
+		OperationID: "delete-schedule-windows",
+		Method:      http.MethodPost,
+		Path:        "/schedule/window/delete",
+		Summary:     "Delete weekly time windows of download queue",
+	}, handler.DeleteScheduleWindows)
+}
+
+func AddCompletionRoutes(handler handlers.CompletionHandler, humaApi huma.API) {
+	huma.Register(humaApi, huma.Operation{
+		OperationID: "get-completion-rules",
+		Method:      http.MethodGet,
+		Path:        "/completion/rule",
+		Summary:     "Get rules that run when a download or torrent is completed",
+	}, handler.GetCompletionRules)
+	huma.Register(humaApi, huma.Operation{
+		OperationID: "add-completion-rule",
+		Method:      http.MethodPost,
+		Path:        "/completion/rule",
+		Summary:     "Add rule that runs when a download or torrent is completed",
+	}, handler.AddCompletionRule)
+	huma.Register(humaApi, huma.Operation{
+		OperationID: "delete-completion-rules",
+		Method:      http.MethodPost,
+		Path:        "/completion/rule/delete",
+		Summary:     "Delete completion rules",
+	}, handler.DeleteCompletionRules)
+	huma.Register(humaApi, huma.Operation{
+		OperationID: "get-completion-results",
+		Method:      http.MethodGet,
+		Path:        "/completion/result",
+		Summary:     "Get results of completion rules that ran for a download or torrent",
+	}, handler.GetCompletionResults)
+}
+
+func AddExtractionRoutes(handler handlers.ExtractionHandler, humaApi huma.API) {
+	huma.Register(humaApi, huma.Operation{
+		OperationID: "get-extractions",
+		Method:      http.MethodGet,
+		Path:        "/extraction",
+		Summary:     "Get extracted archives of a download or torrent with their progress and errors",
+	}, handler.GetExtractions)
+}
+
+func AddHistoryRoutes(handler handlers.HistoryHandler, humaApi huma.API) {
+	huma.Register(humaApi, huma.Operation{
+		OperationID: "get-speed-history",
+		Method:      http.MethodGet,
+		Path:        "/history/speed",
+		Summary:     "Get speed history of a download, a torrent or all transfers",
+	}, handler.GetSpeedHistory)
+	huma.Register(humaApi, huma.Operation{
+		OperationID: "get-daily-totals",
+		Method:      http.MethodGet,
+		Path:        "/history/daily",
+		Summary:     "Get bytes transferred per day",
+	}, handler.GetDailyTotals)
+}
+
+func AddGrabberRoutes(handler handlers.GrabberHandler, humaApi huma.API) {
+	huma.Register(humaApi, huma.Operation{
+		OperationID: "grab-links",
+		Method:      http.MethodPost,
+		Path:        "/grabber",
+		Summary:     "Get files linked from a web page",
+	}, handler.GrabLinks)
+	huma.Register(humaApi, huma.Operation{
+		OperationID: "download-grabbed-links",
+		Method:      http.MethodPost,
+		Path:        "/grabber/download",
+		Summary:     "Download links of a web page as a batch",
+	}, handler.DownloadGrabbedLinks)
+}
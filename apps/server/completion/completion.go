@@ -0,0 +1,334 @@
+package completion
+
+import (
+	"bytes"
+	"context"
+	"downite/db"
+	"downite/download/protocol/direct"
+	"downite/types"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const commandTimeout = 10 * time.Minute
+const webhookTimeout = 30 * time.Second
+
+// output of commands and webhooks is cut to this length before it is saved
+const maxOutputLength = 4096
+
+// events are dropped when this many of them are waiting
+const eventBufferSize = 100
+
+// ActionRunner runs the completion rules for the transfers completed by the engines
+type ActionRunner struct {
+	db             *db.Database
+	downloadEngine *direct.DirectDownloadEngine
+	// command rules can only run these executables
+	allowedCommands []string
+	rules           []*types.CompletionRule
+	mutexForRules   sync.Mutex
+	events          chan types.CompletionEvent
+	stop            chan struct{}
+}
+
+func CreateActionRunner(db *db.Database, downloadEngine *direct.DirectDownloadEngine, completionSettings types.CompletionSettings) *ActionRunner {
+	return &ActionRunner{
+		db:              db,
+		downloadEngine:  downloadEngine,
+		allowedCommands: completionSettings.AllowedCommands,
+		events:          make(chan types.CompletionEvent, eventBufferSize),
+		stop:            make(chan struct{}),
+	}
+}
+
+// InitRules loads the rules from db and starts running them for the completed transfers
+func (runner *ActionRunner) InitRules() error {
+	rules, err := runner.db.GetCompletionRules()
+	if err != nil {
+		return err
+	}
+	runner.mutexForRules.Lock()
+	runner.rules = rules
+	runner.mutexForRules.Unlock()
+
+	go runner.run()
+	return nil
+}
+
+func (runner *ActionRunner) Stop() {
+	close(runner.stop)
+}
+
+// Consume queues the event. it is the completion listener of the engines so it doesn't block them
+func (runner *ActionRunner) Consume(event types.CompletionEvent) {
+	select {
+	case runner.events <- event:
+	default:
+		fmt.Printf("Completion actions are skipped for %s, too many waiting transfers \n", event.Path)
+	}
+}
+
+func (runner *ActionRunner) run() {
+	for {
+		select {
+		case <-runner.stop:
+			return
+		case event := <-runner.events:
+			runner.RunRules(event)
+		}
+	}
+}
+
+// RunRules runs the rules of the event in order and saves their results. rules after a move use the new path.
+// it returns the event with the path after the rules
+func (runner *ActionRunner) RunRules(event types.CompletionEvent) types.CompletionEvent {
+	runner.mutexForRules.Lock()
+	rules := make([]*types.CompletionRule, 0)
+	for _, rule := range runner.rules {
+		if rule.AppliesTo(&event) {
+			rules = append(rules, rule)
+		}
+	}
+	runner.mutexForRules.Unlock()
+
+	for _, rule := range rules {
+		output, err := runner.runAction(rule, &event)
+		result := &types.CompletionResult{
+			CreatedAt:       time.Now(),
+			RuleId:          rule.Id,
+			Action:          rule.Action,
+			DownloadId:      event.DownloadId,
+			TorrentInfohash: event.TorrentInfohash,
+			IsSuccess:       err == nil,
+			Output:          truncate(output),
+		}
+		if err != nil {
+			fmt.Printf("Error while running completion rule %d for %s : %s \n", rule.Id, event.Path, err)
+			result.Error = err.Error()
+		}
+		err = runner.db.InsertCompletionResult(result)
+		if err != nil {
+			fmt.Printf("Error while saving completion result in db : %s \n", err)
+		}
+	}
+	return event
+}
+
+func (runner *ActionRunner) runAction(rule *types.CompletionRule, event *types.CompletionEvent) (string, error) {
+	switch rule.Action {
+	case types.CompletionActionMove.String():
+		return "", runner.move(rule, event)
+	case types.CompletionActionCommand.String():
+		// rules saved before the executable is removed from the allowed commands don't run
+		if !slices.Contains(runner.allowedCommands, rule.Target) {
+			return "", fmt.Errorf("command %s is not in allowed commands of settings", rule.Target)
+		}
+		return runCommand(rule, event)
+	case types.CompletionActionWebhook.String():
+		return runner.callWebhook(rule, event)
+	}
+	return "", fmt.Errorf("unknown action %s", rule.Action)
+}
+
+func (runner *ActionRunner) move(rule *types.CompletionRule, event *types.CompletionEvent) error {
+	if event.DownloadId == 0 {
+		return fmt.Errorf("only downloads can be moved")
+	}
+	err := runner.downloadEngine.MoveDownload(event.DownloadId, rule.Target)
+	if err != nil {
+		return err
+	}
+	event.SavePath = rule.Target
+	event.Path = filepath.Join(rule.Target, event.Name)
+	return nil
+}
+
+// runCommand runs the executable directly without a shell. values of the transfer are given as placeholders in arguments and as environment variables
+func runCommand(rule *types.CompletionRule, event *types.CompletionEvent) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	arguments := make([]string, len(rule.Arguments))
+	for i, argument := range rule.Arguments {
+		arguments[i] = expandPlaceholders(argument, event)
+	}
+	command := exec.CommandContext(ctx, rule.Target, arguments...)
+	command.Env = append(os.Environ(), eventEnvironment(event)...)
+	output, err := command.CombinedOutput()
+	if ctx.Err() != nil {
+		return string(output), fmt.Errorf("command timed out after %s", commandTimeout)
+	}
+	return string(output), err
+}
+
+// callWebhook posts the event as json to the url
+func (runner *ActionRunner) callWebhook(rule *types.CompletionRule, event *types.CompletionEvent) (string, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return "", err
+	}
+	// webhooks use the proxy in settings like downloads
+	httpClient, err := runner.downloadEngine.GetHttpClient("")
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rule.Target, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	output, err := io.ReadAll(io.LimitReader(res.Body, maxOutputLength))
+	if err != nil {
+		return "", err
+	}
+	if res.StatusCode >= 300 {
+		return string(output), fmt.Errorf("webhook responded with status %s", res.Status)
+	}
+	return string(output), nil
+}
+
+func eventValues(event *types.CompletionEvent) map[string]string {
+	id := event.TorrentInfohash
+	if event.DownloadId != 0 {
+		id = strconv.Itoa(event.DownloadId)
+	}
+	return map[string]string{
+		"kind":     event.Kind,
+		"id":       id,
+		"name":     event.Name,
+		"savePath": event.SavePath,
+		"path":     event.Path,
+		"url":      event.Url,
+		"size":     strconv.FormatUint(event.TotalSize, 10),
+	}
+}
+
+// expandPlaceholders replaces {path}, {name}, {savePath}, {size}, {kind}, {id} and {url} in the argument
+func expandPlaceholders(argument string, event *types.CompletionEvent) string {
+	values := eventValues(event)
+	replacements := make([]string, 0, len(values)*2)
+	for key, value := range values {
+		replacements = append(replacements, "{"+key+"}", value)
+	}
+	return strings.NewReplacer(replacements...).Replace(argument)
+}
+
+// eventEnvironment returns the values of the event like DOWNITE_SAVE_PATH=/downloads
+func eventEnvironment(event *types.CompletionEvent) []string {
+	environment := make([]string, 0)
+	for key, value := range eventValues(event) {
+		name := strings.ToUpper(key)
+		if key == "savePath" {
+			name = "SAVE_PATH"
+		}
+		environment = append(environment, "DOWNITE_"+name+"="+value)
+	}
+	return environment
+}
+
+func truncate(output string) string {
+	if len(output) > maxOutputLength {
+		return output[:maxOutputLength]
+	}
+	return output
+}
+
+// validateRule checks the rule before it is saved. commands must be in the allowed commands
+func validateRule(rule *types.CompletionRule, allowedCommands []string) error {
+	if rule.Kind != "all" && rule.Kind != types.TransferKindDownload.String() && rule.Kind != types.TransferKindTorrent.String() {
+		return fmt.Errorf("unknown kind %s", rule.Kind)
+	}
+	switch rule.Action {
+	case types.CompletionActionMove.String():
+		// files of torrents are still seeded after they are completed
+		if rule.Kind != types.TransferKindDownload.String() {
+			return fmt.Errorf("move action is only supported for downloads")
+		}
+		if !filepath.IsAbs(rule.Target) {
+			return fmt.Errorf("target of move action must be an absolute directory")
+		}
+	case types.CompletionActionCommand.String():
+		if rule.Target == "" {
+			return fmt.Errorf("target of command action must be an executable")
+		}
+		if !slices.Contains(allowedCommands, rule.Target) {
+			return fmt.Errorf("command %s is not in allowed commands of settings", rule.Target)
+		}
+	case types.CompletionActionWebhook.String():
+		webhookUrl, err := url.Parse(rule.Target)
+		if err != nil || (webhookUrl.Scheme != "http" && webhookUrl.Scheme != "https") || webhookUrl.Host == "" {
+			return fmt.Errorf("target of webhook action must be an http url")
+		}
+	default:
+		return fmt.Errorf("unknown action %s", rule.Action)
+	}
+	return nil
+}
+
+func (runner *ActionRunner) GetRules() []*types.CompletionRule {
+	runner.mutexForRules.Lock()
+	defer runner.mutexForRules.Unlock()
+
+	rules := make([]*types.CompletionRule, len(runner.rules))
+	copy(rules, runner.rules)
+	return rules
+}
+
+func (runner *ActionRunner) AddRule(rule *types.CompletionRule) error {
+	err := validateRule(rule, runner.allowedCommands)
+	if err != nil {
+		return err
+	}
+	rule.CreatedAt = time.Now()
+	id, err := runner.db.InsertCompletionRule(rule)
+	if err != nil {
+		return err
+	}
+	rule.Id = id
+
+	runner.mutexForRules.Lock()
+	runner.rules = append(runner.rules, rule)
+	runner.mutexForRules.Unlock()
+	return nil
+}
+
+func (runner *ActionRunner) DeleteRule(id int) error {
+	runner.mutexForRules.Lock()
+	defer runner.mutexForRules.Unlock()
+
+	for i, rule := range runner.rules {
+		if rule.Id == id {
+			err := runner.db.DeleteCompletionRule(id)
+			if err != nil {
+				return err
+			}
+			runner.rules = append(runner.rules[:i], runner.rules[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("completion rule not found")
+}
+
+// GetResults returns the results of the rules that ran for the download or the torrent
+func (runner *ActionRunner) GetResults(downloadId int, torrentInfohash string) ([]*types.CompletionResult, error) {
+	return runner.db.GetCompletionResults(downloadId, torrentInfohash)
+}
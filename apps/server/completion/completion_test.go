@@ -0,0 +1,96 @@
+package completion
+
+import (
+	"downite/download/protocol/direct"
+	"downite/types"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var eventMock = types.CompletionEvent{
+	Kind:       "download",
+	DownloadId: 7,
+	Name:       "file.zip",
+	SavePath:   "/downloads",
+	Path:       "/downloads/file.zip",
+	TotalSize:  1024,
+}
+
+func TestExpandPlaceholders(t *testing.T) {
+	expanded := expandPlaceholders("{path}|{id}|{size}|{savePath}|{unknown}", &eventMock)
+	if expanded != "/downloads/file.zip|7|1024|/downloads|{unknown}" {
+		t.Errorf("unexpected expanded argument %s", expanded)
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	rule := &types.CompletionRule{
+		Action:    "command",
+		Target:    "sh",
+		Arguments: types.StringList{"-c", `echo "$1 $DOWNITE_SAVE_PATH"`, "sh", "{name}"},
+	}
+	output, err := runCommand(rule, &eventMock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(output) != "file.zip /downloads" {
+		t.Errorf("unexpected command output %q", output)
+	}
+}
+
+func TestCallWebhook(t *testing.T) {
+	var received types.CompletionEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+		if received.DownloadId != eventMock.DownloadId {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	downloadEngine, err := direct.CreateDownloadClient(&direct.DownloadClientConfig{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner := CreateActionRunner(nil, downloadEngine, types.CompletionSettings{})
+	rule := &types.CompletionRule{Action: "webhook", Target: server.URL}
+	output, err := runner.callWebhook(rule, &eventMock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output != "ok" || received.Path != eventMock.Path {
+		t.Errorf("unexpected webhook output %q and received event %v", output, received)
+	}
+
+	event := eventMock
+	event.DownloadId = 8
+	_, err = runner.callWebhook(rule, &event)
+	if err == nil {
+		t.Errorf("expected error for bad webhook status")
+	}
+}
+
+func TestValidateRule(t *testing.T) {
+	testCases := []struct {
+		rule  types.CompletionRule
+		valid bool
+	}{
+		{types.CompletionRule{Kind: "download", Action: "move", Target: "/done"}, true},
+		{types.CompletionRule{Kind: "all", Action: "move", Target: "/done"}, false},
+		{types.CompletionRule{Kind: "download", Action: "move", Target: "done"}, false},
+		{types.CompletionRule{Kind: "torrent", Action: "command", Target: "notify-send"}, true},
+		{types.CompletionRule{Kind: "torrent", Action: "command", Target: "rm"}, false},
+		{types.CompletionRule{Kind: "all", Action: "webhook", Target: "ftp://host/hook"}, false},
+		{types.CompletionRule{Kind: "all", Action: "webhook", Target: "https://host/hook"}, true},
+	}
+	for _, testCase := range testCases {
+		err := validateRule(&testCase.rule, []string{"notify-send"})
+		if (err == nil) != testCase.valid {
+			t.Errorf("expected rule %v to be valid %t, got %v", testCase.rule, testCase.valid, err)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package db
+
+import "downite/types"
+
+func (db *Database) InsertCompletionRule(rule *types.CompletionRule) (int, error) {
+	result, err := db.x.NamedExec(`INSERT INTO completion_rules
+	(created_at, name, kind, action, target, arguments)
+	VALUES
+	(:created_at, :name, :kind, :action, :target, :arguments)
+	`, rule)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+func (db *Database) GetCompletionRules() ([]*types.CompletionRule, error) {
+	var rules []*types.CompletionRule
+	err := db.x.Select(&rules, `SELECT * FROM completion_rules ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+func (db *Database) DeleteCompletionRule(id int) error {
+	_, err := db.x.Exec(`DELETE FROM completion_rules WHERE id = ?`, id)
+	return err
+}
+
+func (db *Database) InsertCompletionResult(result *types.CompletionResult) error {
+	_, err := db.x.NamedExec(`INSERT INTO completion_results
+	(created_at, rule_id, action, download_id, torrent_infohash, is_success, output, error)
+	VALUES
+	(:created_at, :rule_id, :action, :download_id, :torrent_infohash, :is_success, :output, :error)
+	`, result)
+	return err
+}
+
+// GetCompletionResults returns the results of the rules that ran for the download or the torrent
+func (db *Database) GetCompletionResults(downloadId int, torrentInfohash string) ([]*types.CompletionResult, error) {
+	var results []*types.CompletionResult
+	err := db.x.Select(&results, `SELECT * FROM completion_results
+	WHERE download_id = ? AND torrent_infohash = ?
+	ORDER BY id`, downloadId, torrentInfohash)
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
@@ -16,9 +16,9 @@ func (db *Database) InsertDownload(download *types.Download, addTopOfQueue bool)
 		}
 	}
 	result, err := db.x.NamedExec(`INSERT INTO downloads
-	(created_at, status, name, save_path, part_count, part_length, total_size, downloaded_bytes, is_multi_part, url, queue_number)
+	(created_at, status, name, save_path, part_count, part_length, total_size, downloaded_bytes, is_multi_part, url, queue_number, speed_limit, checksum_algorithm, checksum, headers, page_headers, proxy, credentials, is_hls, segment_count, completed_segment_count, piece_hash_algorithm, piece_length, piece_hashes, etag, last_modified, is_size_unknown, page_url, format_id, batch_id)
 	VALUES
-	(:created_at, :status, :name, :save_path, :part_count, :part_length, :total_size, :downloaded_bytes, :is_multi_part, :url, :queue_number)
+	(:created_at, :status, :name, :save_path, :part_count, :part_length, :total_size, :downloaded_bytes, :is_multi_part, :url, :queue_number, :speed_limit, :checksum_algorithm, :checksum, :headers, :page_headers, :proxy, :credentials, :is_hls, :segment_count, :completed_segment_count, :piece_hash_algorithm, :piece_length, :piece_hashes, :etag, :last_modified, :is_size_unknown, :page_url, :format_id, :batch_id)
 	`, download)
 	if err != nil {
 		return 0, err
@@ -95,7 +95,26 @@ func (db *Database) UpdateDownload(download *types.Download) error {
 		is_multi_part = :is_multi_part,
 		url = :url,
 		queue_number = :queue_number,
-		error = :error
+		error = :error,
+		speed_limit = :speed_limit,
+		checksum_algorithm = :checksum_algorithm,
+		checksum = :checksum,
+		headers = :headers,
+		page_headers = :page_headers,
+		proxy = :proxy,
+		credentials = :credentials,
+		is_hls = :is_hls,
+		segment_count = :segment_count,
+		completed_segment_count = :completed_segment_count,
+		piece_hash_algorithm = :piece_hash_algorithm,
+		piece_length = :piece_length,
+		piece_hashes = :piece_hashes,
+		etag = :etag,
+		last_modified = :last_modified,
+		is_size_unknown = :is_size_unknown,
+		page_url = :page_url,
+		format_id = :format_id,
+		batch_id = :batch_id
 	WHERE
 		id = :id
 	`, download)
@@ -126,6 +145,9 @@ func (db *Database) UpdateDownloadPart(downloadPart *types.DownloadPart) error {
 		time_active = :time_active,
 		finished_at = :finished_at,
 		status = :status,
+		start_byte_index = :start_byte_index,
+		end_byte_index = :end_byte_index,
+		part_length = :part_length,
 		downloaded_bytes = :downloaded_bytes
 	WHERE
 		download_id = :download_id
@@ -133,3 +155,49 @@ func (db *Database) UpdateDownloadPart(downloadPart *types.DownloadPart) error {
 	`, downloadPart)
 	return err
 }
+
+func (db *Database) InsertDownloadMirrors(downloadMirrors []*types.DownloadMirror) error {
+	_, err := db.x.NamedExec(`INSERT INTO download_mirrors
+	(created_at, url, credentials, download_id)
+	VALUES
+	(:created_at, :url, :credentials, :download_id)
+	`, downloadMirrors)
+
+	return err
+}
+func (db *Database) GetDownloadMirrors(downloadId int) ([]*types.DownloadMirror, error) {
+	var downloadMirrors []*types.DownloadMirror
+	err := db.x.Select(&downloadMirrors, `SELECT * FROM download_mirrors WHERE download_id = ? ORDER BY id`, downloadId)
+	if err != nil {
+		return nil, err
+	}
+	return downloadMirrors, nil
+}
+func (db *Database) DeleteDownloadMirrors(downloadId int) error {
+	_, err := db.x.Exec(`DELETE FROM download_mirrors WHERE download_id = ?`, downloadId)
+	return err
+}
+
+func (db *Database) InsertDownloadBatch(batch *types.DownloadBatch) (int, error) {
+	result, err := db.x.NamedExec(`INSERT INTO download_batches
+	(created_at, name, source_url)
+	VALUES
+	(:created_at, :name, :source_url)
+	`, batch)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+func (db *Database) GetDownloadBatches() ([]*types.DownloadBatch, error) {
+	var batches []*types.DownloadBatch
+	err := db.x.Select(&batches, `SELECT * FROM download_batches ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	return batches, nil
+}
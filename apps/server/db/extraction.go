@@ -0,0 +1,51 @@
+package db
+
+import "downite/types"
+
+func (db *Database) InsertExtraction(extraction *types.Extraction) (int, error) {
+	result, err := db.x.NamedExec(`INSERT INTO extractions
+	(created_at, download_id, torrent_infohash, archive_path, extract_path, status)
+	VALUES
+	(:created_at, :download_id, :torrent_infohash, :archive_path, :extract_path, :status)
+	`, extraction)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+func (db *Database) UpdateExtraction(extraction *types.Extraction) error {
+	_, err := db.x.NamedExec(`UPDATE extractions
+	SET
+		status = :status,
+		progress = :progress,
+		is_archive_deleted = :is_archive_deleted,
+		error = :error,
+		finished_at = :finished_at
+	WHERE
+		id = :id
+	`, extraction)
+	return err
+}
+
+// GetExtractions returns the extracted archives of the download or the torrent
+func (db *Database) GetExtractions(downloadId int, torrentInfohash string) ([]*types.Extraction, error) {
+	var extractions []*types.Extraction
+	err := db.x.Select(&extractions, `SELECT * FROM extractions
+	WHERE download_id = ? AND torrent_infohash = ?
+	ORDER BY id`, downloadId, torrentInfohash)
+	if err != nil {
+		return nil, err
+	}
+	return extractions, nil
+}
+
+// FailUnfinishedExtractions marks the extractions that were stopped by closing the app as failed
+func (db *Database) FailUnfinishedExtractions(message string) error {
+	_, err := db.x.Exec(`UPDATE extractions SET status = ?, error = ? WHERE status = ?`,
+		types.ExtractionStatusError.String(), message, types.ExtractionStatusExtracting.String())
+	return err
+}
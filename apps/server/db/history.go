@@ -0,0 +1,26 @@
+package db
+
+import "downite/types"
+
+// AddDailyTransferTotal adds the bytes to the total of the day
+func (db *Database) AddDailyTransferTotal(total *types.DailyTransferTotal) error {
+	_, err := db.x.NamedExec(`INSERT INTO daily_transfer_totals
+	(day, downloaded_bytes, uploaded_bytes)
+	VALUES
+	(:day, :downloaded_bytes, :uploaded_bytes)
+	ON CONFLICT(day) DO UPDATE SET
+		downloaded_bytes = downloaded_bytes + excluded.downloaded_bytes,
+		uploaded_bytes = uploaded_bytes + excluded.uploaded_bytes
+	`, total)
+	return err
+}
+
+// GetDailyTransferTotals returns the totals of the days starting from the day
+func (db *Database) GetDailyTransferTotals(fromDay string) ([]*types.DailyTransferTotal, error) {
+	var totals []*types.DailyTransferTotal
+	err := db.x.Select(&totals, `SELECT * FROM daily_transfer_totals WHERE day >= ? ORDER BY day`, fromDay)
+	if err != nil {
+		return nil, err
+	}
+	return totals, nil
+}
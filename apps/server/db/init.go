@@ -19,7 +19,8 @@ func DbInit() (*Database, error) {
 	if err != nil {
 		return nil, err
 	}
-	x, err := sqlx.Connect("sqlite", filepath.Join(projectRoot, "bin", "downite.db"))
+	// engines write from many goroutines. wait for locks instead of failing with SQLITE_BUSY
+	x, err := sqlx.Connect("sqlite", filepath.Join(projectRoot, "bin", "downite.db")+"?_pragma=busy_timeout(5000)")
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,5 @@
+-- +goose up
+alter table downloads add column speed_limit int not null default 0;
+
+-- +goose down
+alter table downloads drop column speed_limit;
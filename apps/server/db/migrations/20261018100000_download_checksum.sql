@@ -0,0 +1,7 @@
+-- +goose up
+alter table downloads add column checksum_algorithm text not null default '';
+alter table downloads add column checksum text not null default '';
+
+-- +goose down
+alter table downloads drop column checksum;
+alter table downloads drop column checksum_algorithm;
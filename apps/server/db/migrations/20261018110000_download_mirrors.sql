@@ -0,0 +1,11 @@
+-- +goose up
+create table if not exists download_mirrors (
+    id integer primary key,
+    created_at timestamp default current_timestamp,
+    url text not null,
+    download_id int not null,
+    foreign key (download_id) references downloads (id)
+);
+
+-- +goose down
+drop table download_mirrors;
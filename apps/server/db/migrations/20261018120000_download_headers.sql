@@ -0,0 +1,5 @@
+-- +goose up
+alter table downloads add column headers text not null default '';
+
+-- +goose down
+alter table downloads drop column headers;
@@ -0,0 +1,5 @@
+-- +goose up
+alter table downloads add column proxy text not null default '';
+
+-- +goose down
+alter table downloads drop column proxy;
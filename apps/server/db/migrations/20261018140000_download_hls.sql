@@ -0,0 +1,9 @@
+-- +goose up
+alter table downloads add column is_hls boolean not null default false;
+alter table downloads add column segment_count int not null default 0;
+alter table downloads add column completed_segment_count int not null default 0;
+
+-- +goose down
+alter table downloads drop column completed_segment_count;
+alter table downloads drop column segment_count;
+alter table downloads drop column is_hls;
@@ -0,0 +1,9 @@
+-- +goose up
+alter table downloads add column piece_hash_algorithm text not null default '';
+alter table downloads add column piece_length int not null default 0;
+alter table downloads add column piece_hashes text not null default '';
+
+-- +goose down
+alter table downloads drop column piece_hashes;
+alter table downloads drop column piece_length;
+alter table downloads drop column piece_hash_algorithm;
@@ -0,0 +1,7 @@
+-- +goose up
+alter table downloads add column etag text not null default '';
+alter table downloads add column last_modified text not null default '';
+
+-- +goose down
+alter table downloads drop column last_modified;
+alter table downloads drop column etag;
@@ -0,0 +1,5 @@
+-- +goose up
+alter table downloads add column is_size_unknown boolean not null default false;
+
+-- +goose down
+alter table downloads drop column is_size_unknown;
@@ -0,0 +1,23 @@
+-- +goose up
+create table if not exists scheduled_tasks (
+    id integer primary key,
+    created_at timestamp default current_timestamp,
+    run_at timestamp not null,
+    action text not null,
+    download_id int not null default 0,
+    torrent_infohash text not null default '',
+    finished_at timestamp default null,
+    error text not null default ''
+);
+
+create table if not exists schedule_windows (
+    id integer primary key,
+    created_at timestamp default current_timestamp,
+    weekday int not null,
+    start_time text not null,
+    end_time text not null
+);
+
+-- +goose down
+drop table schedule_windows;
+drop table scheduled_tasks;
@@ -0,0 +1,26 @@
+-- +goose up
+create table if not exists completion_rules (
+    id integer primary key,
+    created_at timestamp default current_timestamp,
+    name text not null default '',
+    kind text not null,
+    action text not null,
+    target text not null,
+    arguments text not null default ''
+);
+
+create table if not exists completion_results (
+    id integer primary key,
+    created_at timestamp default current_timestamp,
+    rule_id int not null,
+    action text not null,
+    download_id int not null default 0,
+    torrent_infohash text not null default '',
+    is_success boolean not null default false,
+    output text not null default '',
+    error text not null default ''
+);
+
+-- +goose down
+drop table completion_results;
+drop table completion_rules;
@@ -0,0 +1,17 @@
+-- +goose up
+create table if not exists extractions (
+    id integer primary key,
+    created_at timestamp default current_timestamp,
+    download_id int not null default 0,
+    torrent_infohash text not null default '',
+    archive_path text not null,
+    extract_path text not null,
+    status text not null,
+    progress real not null default 0,
+    is_archive_deleted boolean not null default false,
+    error text not null default '',
+    finished_at timestamp default null
+);
+
+-- +goose down
+drop table extractions;
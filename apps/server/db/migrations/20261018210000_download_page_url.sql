@@ -0,0 +1,7 @@
+-- +goose up
+alter table downloads add column page_url text not null default '';
+alter table downloads add column format_id text not null default '';
+
+-- +goose down
+alter table downloads drop column format_id;
+alter table downloads drop column page_url;
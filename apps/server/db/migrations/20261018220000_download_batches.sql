@@ -0,0 +1,13 @@
+-- +goose up
+create table if not exists download_batches (
+    id integer primary key,
+    created_at timestamp default current_timestamp,
+    name text not null default '',
+    source_url text not null default ''
+);
+
+alter table downloads add column batch_id int not null default 0;
+
+-- +goose down
+alter table downloads drop column batch_id;
+drop table download_batches;
@@ -0,0 +1,9 @@
+-- +goose up
+create table if not exists daily_transfer_totals (
+    day text primary key,
+    downloaded_bytes integer not null default 0,
+    uploaded_bytes integer not null default 0
+);
+
+-- +goose down
+drop table daily_transfer_totals;
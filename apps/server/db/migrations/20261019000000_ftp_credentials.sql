@@ -0,0 +1,7 @@
+-- +goose up
+alter table downloads add column credentials text not null default '';
+alter table download_mirrors add column credentials text not null default '';
+
+-- +goose down
+alter table download_mirrors drop column credentials;
+alter table downloads drop column credentials;
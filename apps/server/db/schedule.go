@@ -0,0 +1,69 @@
+package db
+
+import "downite/types"
+
+func (db *Database) InsertScheduledTask(task *types.ScheduledTask) (int, error) {
+	result, err := db.x.NamedExec(`INSERT INTO scheduled_tasks
+	(created_at, run_at, action, download_id, torrent_infohash)
+	VALUES
+	(:created_at, :run_at, :action, :download_id, :torrent_infohash)
+	`, task)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+func (db *Database) GetScheduledTasks() ([]*types.ScheduledTask, error) {
+	var tasks []*types.ScheduledTask
+	err := db.x.Select(&tasks, `SELECT * FROM scheduled_tasks ORDER BY run_at`)
+	if err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+func (db *Database) UpdateScheduledTask(task *types.ScheduledTask) error {
+	_, err := db.x.NamedExec(`UPDATE scheduled_tasks
+	SET
+		finished_at = :finished_at,
+		error = :error
+	WHERE
+		id = :id
+	`, task)
+	return err
+}
+func (db *Database) DeleteScheduledTask(id int) error {
+	_, err := db.x.Exec(`DELETE FROM scheduled_tasks WHERE id = ?`, id)
+	return err
+}
+
+func (db *Database) InsertScheduleWindow(window *types.ScheduleWindow) (int, error) {
+	result, err := db.x.NamedExec(`INSERT INTO schedule_windows
+	(created_at, weekday, start_time, end_time)
+	VALUES
+	(:created_at, :weekday, :start_time, :end_time)
+	`, window)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+func (db *Database) GetScheduleWindows() ([]*types.ScheduleWindow, error) {
+	var windows []*types.ScheduleWindow
+	err := db.x.Select(&windows, `SELECT * FROM schedule_windows ORDER BY weekday, start_time`)
+	if err != nil {
+		return nil, err
+	}
+	return windows, nil
+}
+func (db *Database) DeleteScheduleWindow(id int) error {
+	_, err := db.x.Exec(`DELETE FROM schedule_windows WHERE id = ?`, id)
+	return err
+}
@@ -0,0 +1,311 @@
+package grabber
+
+import (
+	"context"
+	"downite/types"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+)
+
+// pages bigger than this are not read to the end
+const maxPageSize = 10 * 1024 * 1024
+
+// attributes that link to files by tag
+var linkAttributes = map[string][]string{
+	"a":      {"href"},
+	"img":    {"src", "srcset"},
+	"video":  {"src", "poster"},
+	"audio":  {"src"},
+	"source": {"src", "srcset"},
+	"track":  {"src"},
+	"embed":  {"src"},
+}
+
+// Link is a file linked from a page
+type Link struct {
+	Url string `json:"url"`
+	// text of the anchor or alt of the image
+	Text      string `json:"text"`
+	Tag       string `json:"tag"`
+	FileName  string `json:"fileName"`
+	Extension string `json:"extension"`
+	MimeType  string `json:"mimeType"`
+	Size      uint64 `json:"size"`
+	// size is unknown when the server doesn't send it or the HEAD request fails
+	IsSizeUnknown bool   `json:"isSizeUnknown"`
+	Error         string `json:"error"`
+}
+
+// Filter chooses the links to keep. empty fields match every link
+type Filter struct {
+	// extensions like zip or .zip
+	Extensions []string
+	// mime types like video/mp4 or prefixes like video/
+	MimeTypes []string
+	// regular expression matched against the url
+	Pattern *regexp.Regexp
+}
+
+// matchesPattern reports whether the url of the link matches the pattern
+func (filter *Filter) matchesPattern(link *Link) bool {
+	return filter.Pattern == nil || filter.Pattern.MatchString(link.Url)
+}
+
+// matchesExtension reports whether the extension of the link matches. it is checked after the HEAD request
+// because the name the server sends can have another extension than the url
+func (filter *Filter) matchesExtension(link *Link) bool {
+	if len(filter.Extensions) == 0 {
+		return true
+	}
+	for _, extension := range filter.Extensions {
+		if strings.EqualFold(strings.TrimPrefix(extension, "."), strings.TrimPrefix(link.Extension, ".")) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesMimeType reports whether the mime type of the link matches. links of unknown type don't match when mime types are given
+func (filter *Filter) matchesMimeType(link *Link) bool {
+	if len(filter.MimeTypes) == 0 {
+		return true
+	}
+	for _, mimeType := range filter.MimeTypes {
+		if strings.HasSuffix(mimeType, "/") && strings.HasPrefix(link.MimeType, mimeType) || strings.EqualFold(mimeType, link.MimeType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Grab returns the links of the page that match the filter with their sizes and mime types. links are checked with HEAD requests concurrently.
+// credentials of the headers are only sent to links on the host of the page
+func Grab(ctx context.Context, httpClient *http.Client, pageUrl string, headers types.DownloadHeaders, filter *Filter, concurrency int) ([]*Link, error) {
+	parsedPageUrl, err := url.Parse(pageUrl)
+	if err != nil {
+		return nil, err
+	}
+	if parsedPageUrl.Scheme != "http" && parsedPageUrl.Scheme != "https" {
+		return nil, fmt.Errorf("page url must be an http or https url")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", pageUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	headers.Apply(req)
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("page returned status %s", res.Status)
+	}
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if mediaType != "" && mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, fmt.Errorf("page is not an html page but %s", mediaType)
+	}
+
+	// links are relative to the final url after redirects
+	links, err := ExtractLinks(res.Request.URL, io.LimitReader(res.Body, maxPageSize))
+	if err != nil {
+		return nil, err
+	}
+
+	matchedLinks := make([]*Link, 0, len(links))
+	for _, link := range links {
+		if filter.matchesPattern(link) {
+			matchedLinks = append(matchedLinks, link)
+		}
+	}
+
+	semaphore := make(chan struct{}, max(concurrency, 1))
+	var waitGroup sync.WaitGroup
+	for _, link := range matchedLinks {
+		waitGroup.Add(1)
+		semaphore <- struct{}{}
+		go func() {
+			defer waitGroup.Done()
+			defer func() { <-semaphore }()
+			headLink(ctx, httpClient, link, headers, pageUrl)
+		}()
+	}
+	waitGroup.Wait()
+
+	grabbedLinks := make([]*Link, 0, len(matchedLinks))
+	for _, link := range matchedLinks {
+		if filter.matchesExtension(link) && filter.matchesMimeType(link) {
+			grabbedLinks = append(grabbedLinks, link)
+		}
+	}
+	return grabbedLinks, nil
+}
+
+// ExtractLinks returns the http, https and ftp links of the html in the order they appear. every url is listed once
+func ExtractLinks(pageUrl *url.URL, body io.Reader) ([]*Link, error) {
+	baseUrl := pageUrl
+	links := make([]*Link, 0)
+	linksByUrl := make(map[string]*Link)
+	// text of the anchor that is being read
+	var anchor *Link
+
+	tokenizer := html.NewTokenizer(body)
+	for {
+		tokenType := tokenizer.Next()
+		switch tokenType {
+		case html.ErrorToken:
+			if tokenizer.Err() == io.EOF {
+				return links, nil
+			}
+			return nil, tokenizer.Err()
+		case html.TextToken:
+			if anchor != nil && len(anchor.Text) < 200 {
+				anchor.Text += string(tokenizer.Text())
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			if string(name) == "a" && anchor != nil {
+				anchor.Text = strings.Join(strings.Fields(anchor.Text), " ")
+				anchor = nil
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			if token.Data == "base" {
+				if href := attribute(token, "href"); href != "" {
+					if parsedUrl, err := pageUrl.Parse(href); err == nil {
+						baseUrl = parsedUrl
+					}
+				}
+				continue
+			}
+			attributeNames, ok := linkAttributes[token.Data]
+			if !ok {
+				continue
+			}
+			for _, attributeName := range attributeNames {
+				value := attribute(token, attributeName)
+				if value == "" {
+					continue
+				}
+				rawUrls := []string{value}
+				if attributeName == "srcset" {
+					rawUrls = parseSrcset(value)
+				}
+				for _, rawUrl := range rawUrls {
+					link := newLink(baseUrl, rawUrl, token.Data)
+					if link == nil {
+						continue
+					}
+					existingLink, ok := linksByUrl[link.Url]
+					if ok {
+						link = existingLink
+					} else {
+						linksByUrl[link.Url] = link
+						links = append(links, link)
+					}
+					if token.Data == "img" && link.Text == "" {
+						link.Text = attribute(token, "alt")
+					}
+					if token.Data == "a" && tokenType == html.StartTagToken && link.Text == "" {
+						anchor = link
+					}
+				}
+			}
+		}
+	}
+}
+
+// newLink returns the link of the url relative to the base url. it returns nil for urls that are not files like mailto or javascript urls
+func newLink(baseUrl *url.URL, rawUrl string, tag string) *Link {
+	parsedUrl, err := baseUrl.Parse(strings.TrimSpace(rawUrl))
+	if err != nil {
+		return nil
+	}
+	if parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https" && parsedUrl.Scheme != "ftp" {
+		return nil
+	}
+	parsedUrl.Fragment = ""
+	parsedUrl.RawFragment = ""
+	// links to the page itself are not files
+	if parsedUrl.String() == baseUrl.String() {
+		return nil
+	}
+	fileName, _ := url.PathUnescape(path.Base(parsedUrl.Path))
+	if fileName == "." || fileName == "/" {
+		fileName = ""
+	}
+	return &Link{
+		Url:       parsedUrl.String(),
+		Tag:       tag,
+		FileName:  fileName,
+		Extension: path.Ext(fileName),
+	}
+}
+
+// parseSrcset returns the urls of a srcset like "a.jpg 1x, b.jpg 2x"
+func parseSrcset(srcset string) []string {
+	urls := make([]string, 0)
+	for _, candidate := range strings.Split(srcset, ",") {
+		fields := strings.Fields(candidate)
+		if len(fields) > 0 {
+			urls = append(urls, fields[0])
+		}
+	}
+	return urls
+}
+
+func attribute(token html.Token, name string) string {
+	for _, attr := range token.Attr {
+		if attr.Key == name {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+// headLink sets the size and the mime type of the link from a HEAD request. ftp links are not checked
+func headLink(ctx context.Context, httpClient *http.Client, link *Link, headers types.DownloadHeaders, pageUrl string) {
+	link.IsSizeUnknown = true
+	if strings.HasPrefix(link.Url, "ftp:") {
+		return
+	}
+	req, err := http.NewRequestWithContext(ctx, "HEAD", link.Url, nil)
+	if err != nil {
+		link.Error = err.Error()
+		return
+	}
+	headers.ForUrl(pageUrl, link.Url).Apply(req)
+	req.Header.Set("Referer", pageUrl)
+	res, err := httpClient.Do(req)
+	if err != nil {
+		link.Error = err.Error()
+		return
+	}
+	res.Body.Close()
+	if res.StatusCode >= 400 {
+		link.Error = fmt.Sprintf("HEAD request returned status %s", res.Status)
+		return
+	}
+
+	link.MimeType, _, _ = mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if size, err := strconv.ParseUint(res.Header.Get("Content-Length"), 10, 64); err == nil {
+		link.Size = size
+		link.IsSizeUnknown = false
+	}
+	// file name of the server is better than the name in the url
+	if _, params, err := mime.ParseMediaType(res.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		link.FileName = path.Base(params["filename"])
+		link.Extension = path.Ext(link.FileName)
+	}
+}
@@ -0,0 +1,115 @@
+package grabber
+
+import (
+	"context"
+	"downite/types"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const testPage = `<html><head><base href="/files/"></head><body>
+<a href="a.zip">Archive <b>A</b></a>
+<a href="a.zip#readme">same archive</a>
+<a href="mailto:someone@example.com">mail</a>
+<a href="javascript:void(0)">script</a>
+<a href="http://other.example.com/b.ZIP">B</a>
+<img src="img/photo%20one.jpg" alt="Photo" srcset="img/small.jpg 1x, img/large.jpg 2x">
+<video poster="poster.png"><source src="movie.mp4" type="video/mp4"></video>
+<a href="ftp://ftp.example.com/c.iso">C</a>
+</body></html>`
+
+func TestExtractLinks(t *testing.T) {
+	pageUrl, _ := url.Parse("https://example.com/page/index.html")
+	links, err := ExtractLinks(pageUrl, strings.NewReader(testPage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedUrls := []string{
+		"https://example.com/files/a.zip",
+		"http://other.example.com/b.ZIP",
+		"https://example.com/files/img/photo%20one.jpg",
+		"https://example.com/files/img/small.jpg",
+		"https://example.com/files/img/large.jpg",
+		"https://example.com/files/poster.png",
+		"https://example.com/files/movie.mp4",
+		"ftp://ftp.example.com/c.iso",
+	}
+	if len(links) != len(expectedUrls) {
+		t.Fatalf("expected %d links, got %d", len(expectedUrls), len(links))
+	}
+	for i, link := range links {
+		if link.Url != expectedUrls[i] {
+			t.Errorf("expected %s, got %s", expectedUrls[i], link.Url)
+		}
+	}
+	if links[0].Text != "Archive A" || links[2].Text != "Photo" || links[2].FileName != "photo one.jpg" {
+		t.Errorf("unexpected texts or names %q %q %q", links[0].Text, links[2].Text, links[2].FileName)
+	}
+}
+
+func TestGrab(t *testing.T) {
+	otherHostCookie := "not requested"
+	otherServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		otherHostCookie = r.Header.Get("Cookie")
+		w.Header().Set("Content-Type", "application/zip")
+	}))
+	defer otherServer.Close()
+	// other server is on another host than the page
+	otherUrl := strings.Replace(otherServer.URL, "127.0.0.1", "localhost", 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(`<a href="/a.zip">a</a><a href="/b.zip">b</a><a href="/c.mp4">c</a><a href="/d">d</a><a href="` + otherUrl + `/e.zip">e</a>`))
+		case "/a.zip":
+			if r.Header.Get("Referer") == "" || r.Header.Get("Cookie") != "session=1" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.Header().Set("Content-Type", "application/zip")
+			w.Header().Set("Content-Length", "1234")
+		case "/c.mp4":
+			w.Header().Set("Content-Type", "video/mp4")
+		case "/d":
+			w.Header().Set("Content-Type", "video/webm")
+			w.Header().Set("Content-Disposition", `attachment; filename="d.webm"`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	headers := types.DownloadHeaders{"Cookie": "session=1"}
+	links, err := Grab(context.Background(), server.Client(), server.URL, headers, &Filter{Extensions: []string{"zip"}}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 3 || links[0].Size != 1234 || links[0].MimeType != "application/zip" || links[1].Error == "" {
+		t.Errorf("unexpected zip links %+v", links)
+	}
+	if otherHostCookie != "" {
+		t.Errorf("credentials must not be sent to other hosts, got cookie %q", otherHostCookie)
+	}
+
+	// extension of the name sent by the server is matched
+	links, err = Grab(context.Background(), server.Client(), server.URL, nil, &Filter{Extensions: []string{"webm"}}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 1 || links[0].FileName != "d.webm" {
+		t.Errorf("unexpected webm links %+v", links)
+	}
+
+	links, err = Grab(context.Background(), server.Client(), server.URL, nil, &Filter{MimeTypes: []string{"video/"}, Pattern: regexp.MustCompile(`/[cd]`)}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 2 || !links[0].IsSizeUnknown || links[1].FileName != "d.webm" {
+		t.Errorf("unexpected video links %+v", links)
+	}
+}
@@ -0,0 +1,248 @@
+package metalink
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	namespaceV4 = "urn:ietf:params:xml:ns:metalink"
+	namespaceV3 = "http://www.metalinker.org/"
+)
+
+// urls without a priority come after every url with one
+const lowestPriority = 1000000
+
+// hash algorithms in order of preference. names are normalized like sha-256 to sha256
+var hashAlgorithms = []string{"sha512", "sha256", "sha1", "md5"}
+
+type Metalink struct {
+	Files []*File
+}
+
+// File is a file of the metalink with every resource it can be downloaded from
+type File struct {
+	// relative path of the file. it may contain directories
+	Name string
+	// 0 when the size is not given
+	Size uint64
+	// hex hashes of the whole file by algorithm
+	Hashes             map[string]string
+	PieceLength        uint64
+	PieceHashAlgorithm string
+	PieceHashes        []string
+	// http, https, ftp and ftps urls. lower priority is preferred
+	Urls []*Url
+	// urls of torrent files or magnet links of the file
+	TorrentUrls []string
+}
+
+type Url struct {
+	Url      string
+	Priority int
+	Location string
+}
+
+type xmlMetalink struct {
+	XMLName xml.Name  `xml:"metalink"`
+	Files   []xmlFile `xml:"file"`
+	// version 3 puts files into a files element
+	V3Files []xmlFile `xml:"files>file"`
+}
+
+type xmlFile struct {
+	Name     string       `xml:"name,attr"`
+	Size     uint64       `xml:"size"`
+	Hashes   []xmlHash    `xml:"hash"`
+	Pieces   *xmlPieces   `xml:"pieces"`
+	Urls     []xmlUrl     `xml:"url"`
+	MetaUrls []xmlMetaUrl `xml:"metaurl"`
+	// version 3 keeps hashes and urls in their own elements
+	Verification struct {
+		Hashes []xmlHash  `xml:"hash"`
+		Pieces *xmlPieces `xml:"pieces"`
+	} `xml:"verification"`
+	Resources struct {
+		Urls []xmlUrl `xml:"url"`
+	} `xml:"resources"`
+}
+
+type xmlHash struct {
+	Type  string `xml:"type,attr"`
+	Piece int    `xml:"piece,attr"`
+	Value string `xml:",chardata"`
+}
+
+type xmlPieces struct {
+	Length uint64    `xml:"length,attr"`
+	Type   string    `xml:"type,attr"`
+	Hashes []xmlHash `xml:"hash"`
+}
+
+type xmlUrl struct {
+	Priority   int    `xml:"priority,attr"`
+	Preference int    `xml:"preference,attr"`
+	Type       string `xml:"type,attr"`
+	Location   string `xml:"location,attr"`
+	Value      string `xml:",chardata"`
+}
+
+type xmlMetaUrl struct {
+	Priority  int    `xml:"priority,attr"`
+	MediaType string `xml:"mediatype,attr"`
+	Value     string `xml:",chardata"`
+}
+
+// Parse reads a metalink 4 (.meta4) or metalink 3 (.metalink) document
+func Parse(reader io.Reader) (*Metalink, error) {
+	var document xmlMetalink
+	err := xml.NewDecoder(reader).Decode(&document)
+	if err != nil {
+		return nil, fmt.Errorf("while parsing metalink : %s", err)
+	}
+
+	isV3 := document.XMLName.Space == namespaceV3
+	if !isV3 && document.XMLName.Space != namespaceV4 {
+		return nil, fmt.Errorf("unknown metalink namespace : %s", document.XMLName.Space)
+	}
+	xmlFiles := document.Files
+	if isV3 {
+		xmlFiles = document.V3Files
+	}
+
+	metalink := &Metalink{}
+	for _, xmlFile := range xmlFiles {
+		file, err := parseFile(xmlFile, isV3)
+		if err != nil {
+			return nil, err
+		}
+		metalink.Files = append(metalink.Files, file)
+	}
+	if len(metalink.Files) == 0 {
+		return nil, fmt.Errorf("metalink has no files")
+	}
+	return metalink, nil
+}
+
+func parseFile(xmlFile xmlFile, isV3 bool) (*File, error) {
+	name := strings.TrimSpace(xmlFile.Name)
+	if name == "" || path.IsAbs(name) || strings.Contains(name, "\\") {
+		return nil, fmt.Errorf("invalid file name in metalink : %q", xmlFile.Name)
+	}
+	for _, element := range strings.Split(name, "/") {
+		if element == ".." {
+			return nil, fmt.Errorf("invalid file name in metalink : %q", xmlFile.Name)
+		}
+	}
+
+	file := &File{
+		Name:   path.Clean(name),
+		Size:   xmlFile.Size,
+		Hashes: make(map[string]string),
+	}
+
+	hashes := xmlFile.Hashes
+	pieces := xmlFile.Pieces
+	urls := xmlFile.Urls
+	if isV3 {
+		hashes = xmlFile.Verification.Hashes
+		pieces = xmlFile.Verification.Pieces
+		urls = xmlFile.Resources.Urls
+	}
+
+	for _, hash := range hashes {
+		file.Hashes[normalizeAlgorithm(hash.Type)] = strings.ToLower(strings.TrimSpace(hash.Value))
+	}
+	if pieces != nil && pieces.Length > 0 && len(pieces.Hashes) > 0 {
+		pieceHashes := pieces.Hashes
+		// version 3 numbers the pieces
+		sort.SliceStable(pieceHashes, func(i, j int) bool {
+			return pieceHashes[i].Piece < pieceHashes[j].Piece
+		})
+		file.PieceLength = pieces.Length
+		file.PieceHashAlgorithm = normalizeAlgorithm(pieces.Type)
+		for _, hash := range pieceHashes {
+			file.PieceHashes = append(file.PieceHashes, strings.ToLower(strings.TrimSpace(hash.Value)))
+		}
+	}
+
+	for _, xmlUrl := range urls {
+		rawUrl := strings.TrimSpace(xmlUrl.Value)
+		priority := xmlUrl.Priority
+		if isV3 {
+			// preference is between 0 and 100. higher is preferred
+			priority = lowestPriority
+			if xmlUrl.Preference > 0 {
+				priority = 101 - xmlUrl.Preference
+			}
+		} else if priority <= 0 {
+			priority = lowestPriority
+		}
+		if strings.EqualFold(xmlUrl.Type, "bittorrent") || strings.HasPrefix(rawUrl, "magnet:") {
+			file.TorrentUrls = append(file.TorrentUrls, rawUrl)
+			continue
+		}
+		if !isDirectUrl(rawUrl) {
+			continue
+		}
+		file.Urls = append(file.Urls, &Url{Url: rawUrl, Priority: priority, Location: xmlUrl.Location})
+	}
+	sort.SliceStable(file.Urls, func(i, j int) bool {
+		return file.Urls[i].Priority < file.Urls[j].Priority
+	})
+
+	metaUrls := xmlFile.MetaUrls
+	sort.SliceStable(metaUrls, func(i, j int) bool {
+		return metaUrls[i].Priority < metaUrls[j].Priority
+	})
+	for _, metaUrl := range metaUrls {
+		if strings.EqualFold(metaUrl.MediaType, "torrent") {
+			file.TorrentUrls = append(file.TorrentUrls, strings.TrimSpace(metaUrl.Value))
+		}
+	}
+
+	if len(file.Urls) == 0 && len(file.TorrentUrls) == 0 {
+		return nil, fmt.Errorf("file %s has no supported urls", file.Name)
+	}
+	return file, nil
+}
+
+func normalizeAlgorithm(algorithm string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(algorithm)), "-", "")
+}
+
+func isDirectUrl(rawUrl string) bool {
+	parsedUrl, err := url.Parse(rawUrl)
+	if err != nil {
+		return false
+	}
+	switch parsedUrl.Scheme {
+	case "http", "https", "ftp", "ftps":
+		return true
+	}
+	return false
+}
+
+// DirectUrls returns the urls of the file in order of priority
+func (file *File) DirectUrls() []string {
+	urls := make([]string, 0, len(file.Urls))
+	for _, fileUrl := range file.Urls {
+		urls = append(urls, fileUrl.Url)
+	}
+	return urls
+}
+
+// Checksum returns the strongest hash of the whole file. algorithm is empty when the file has no hashes
+func (file *File) Checksum() (string, string) {
+	for _, algorithm := range hashAlgorithms {
+		if checksum, ok := file.Hashes[algorithm]; ok {
+			return algorithm, checksum
+		}
+	}
+	return "", ""
+}
@@ -0,0 +1,106 @@
+package metalink
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMetalink4(t *testing.T) {
+	document := `<?xml version="1.0" encoding="UTF-8"?>
+<metalink xmlns="urn:ietf:params:xml:ns:metalink">
+  <file name="dir/example.ext">
+    <size>14471447</size>
+    <hash type="md5">d41d8cd98f00b204e9800998ecf8427e</hash>
+    <hash type="sha-256">F0AD929CD259957E160EA442EB80986B5F01E1C2E1B8EB5A2E7B5F1E2EA5A6C8</hash>
+    <pieces length="262144" type="sha-1">
+      <hash>da39a3ee5e6b4b0d3255bfef95601890afd80709</hash>
+      <hash>a94a8fe5ccb19ba61c4c0873d391e987982fbbd3</hash>
+    </pieces>
+    <url location="de" priority="2">ftp://ftp.example.com/example.ext</url>
+    <url location="fr" priority="1">http://example.com/example.ext</url>
+    <url>https://slow.example.com/example.ext</url>
+    <metaurl mediatype="torrent" priority="1">http://example.com/example.ext.torrent</metaurl>
+  </file>
+</metalink>`
+	parsedMetalink, err := Parse(strings.NewReader(document))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsedMetalink.Files) != 1 {
+		t.Fatalf("expected 1 file got %d", len(parsedMetalink.Files))
+	}
+	file := parsedMetalink.Files[0]
+	if file.Name != "dir/example.ext" || file.Size != 14471447 {
+		t.Errorf("unexpected file %+v", file)
+	}
+
+	urls := file.DirectUrls()
+	expectedUrls := []string{"http://example.com/example.ext", "ftp://ftp.example.com/example.ext", "https://slow.example.com/example.ext"}
+	if strings.Join(urls, " ") != strings.Join(expectedUrls, " ") {
+		t.Errorf("urls are not ordered by priority : %v", urls)
+	}
+	if len(file.TorrentUrls) != 1 || file.TorrentUrls[0] != "http://example.com/example.ext.torrent" {
+		t.Errorf("unexpected torrent urls %v", file.TorrentUrls)
+	}
+
+	algorithm, checksum := file.Checksum()
+	if algorithm != "sha256" || checksum != "f0ad929cd259957e160ea442eb80986b5f01e1c2e1b8eb5a2e7b5f1e2ea5a6c8" {
+		t.Errorf("strongest checksum is not picked : %s %s", algorithm, checksum)
+	}
+	if file.PieceHashAlgorithm != "sha1" || file.PieceLength != 262144 || len(file.PieceHashes) != 2 {
+		t.Errorf("unexpected pieces %s %d %v", file.PieceHashAlgorithm, file.PieceLength, file.PieceHashes)
+	}
+}
+
+func TestParseMetalink3(t *testing.T) {
+	document := `<?xml version="1.0" encoding="UTF-8"?>
+<metalink version="3.0" xmlns="http://www.metalinker.org/">
+  <files>
+    <file name="example.iso">
+      <size>1024</size>
+      <verification>
+        <hash type="sha1">a94a8fe5ccb19ba61c4c0873d391e987982fbbd3</hash>
+        <pieces length="512" type="sha1">
+          <hash piece="1">a94a8fe5ccb19ba61c4c0873d391e987982fbbd3</hash>
+          <hash piece="0">da39a3ee5e6b4b0d3255bfef95601890afd80709</hash>
+        </pieces>
+      </verification>
+      <resources>
+        <url type="http" preference="10">http://mirror.example.com/example.iso</url>
+        <url type="http" preference="90">http://example.com/example.iso</url>
+        <url type="bittorrent" preference="100">http://example.com/example.iso.torrent</url>
+      </resources>
+    </file>
+  </files>
+</metalink>`
+	parsedMetalink, err := Parse(strings.NewReader(document))
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := parsedMetalink.Files[0]
+	urls := file.DirectUrls()
+	if len(urls) != 2 || urls[0] != "http://example.com/example.iso" {
+		t.Errorf("urls are not ordered by preference : %v", urls)
+	}
+	if len(file.TorrentUrls) != 1 {
+		t.Errorf("bittorrent url is not found : %v", file.TorrentUrls)
+	}
+	if file.PieceHashes[0] != "da39a3ee5e6b4b0d3255bfef95601890afd80709" {
+		t.Errorf("pieces are not ordered : %v", file.PieceHashes)
+	}
+}
+
+func TestParseInvalidMetalink(t *testing.T) {
+	documents := map[string]string{
+		"unknown namespace": `<metalink xmlns="urn:example"><file name="a"><url>http://example.com/a</url></file></metalink>`,
+		"parent directory":  `<metalink xmlns="urn:ietf:params:xml:ns:metalink"><file name="../a"><url>http://example.com/a</url></file></metalink>`,
+		"no urls":           `<metalink xmlns="urn:ietf:params:xml:ns:metalink"><file name="a"><url>gopher://example.com/a</url></file></metalink>`,
+		"no files":          `<metalink xmlns="urn:ietf:params:xml:ns:metalink"></metalink>`,
+	}
+	for name, document := range documents {
+		_, err := Parse(strings.NewReader(document))
+		if err == nil {
+			t.Errorf("%s : expected error", name)
+		}
+	}
+}
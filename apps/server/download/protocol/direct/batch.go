@@ -0,0 +1,182 @@
+package direct
+
+import (
+	"downite/types"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// patterns with more urls than this are rejected
+const maxPatternUrlCount = 10000
+
+// range patterns like [001-120], [a-z] or [A-Z]
+var urlRangePatternRegex = regexp.MustCompile(`\[(?:(\d+)-(\d+)|([a-z])-([a-z])|([A-Z])-([A-Z]))\]`)
+
+// ExpandUrlPatterns returns the urls of the lines of the text. empty lines and lines starting with # are skipped.
+// range patterns are expanded like file[001-003].jpg to file001.jpg, file002.jpg and file003.jpg
+func ExpandUrlPatterns(text string) ([]string, error) {
+	urls := make([]string, 0)
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lineUrls, err := expandUrlPattern(line, maxPatternUrlCount-len(urls))
+		if err != nil {
+			return nil, fmt.Errorf("%s : %s", line, err)
+		}
+		urls = append(urls, lineUrls...)
+	}
+	return urls, nil
+}
+
+// expandUrlPattern expands the range patterns of the url. every combination of the ranges is returned
+func expandUrlPattern(rawUrl string, maxCount int) ([]string, error) {
+	match := urlRangePatternRegex.FindStringSubmatchIndex(rawUrl)
+	if match == nil {
+		if maxCount < 1 {
+			return nil, fmt.Errorf("more than %d urls", maxPatternUrlCount)
+		}
+		return []string{rawUrl}, nil
+	}
+	values, err := rangeValues(rawUrl, match)
+	if err != nil {
+		return nil, err
+	}
+	prefix := rawUrl[:match[0]]
+	suffixes, err := expandUrlPattern(rawUrl[match[1]:], maxCount/len(values))
+	if err != nil {
+		return nil, err
+	}
+	urls := make([]string, 0, len(values)*len(suffixes))
+	for _, value := range values {
+		for _, suffix := range suffixes {
+			urls = append(urls, prefix+value+suffix)
+		}
+	}
+	return urls, nil
+}
+
+// rangeValues returns the values of the range pattern found at the match indexes
+func rangeValues(rawUrl string, match []int) ([]string, error) {
+	values := make([]string, 0)
+	if match[2] != -1 {
+		startText, endText := rawUrl[match[2]:match[3]], rawUrl[match[4]:match[5]]
+		start, err := strconv.Atoi(startText)
+		if err != nil {
+			return nil, err
+		}
+		end, err := strconv.Atoi(endText)
+		if err != nil {
+			return nil, err
+		}
+		if start > end {
+			return nil, fmt.Errorf("range [%s-%s] ends before it starts", startText, endText)
+		}
+		if end-start >= maxPatternUrlCount {
+			return nil, fmt.Errorf("more than %d urls", maxPatternUrlCount)
+		}
+		// numbers are padded with zeros like the start of the range
+		width := 0
+		if len(startText) > 1 && startText[0] == '0' {
+			width = len(startText)
+		}
+		for number := start; number <= end; number++ {
+			values = append(values, fmt.Sprintf("%0*d", width, number))
+		}
+		return values, nil
+	}
+
+	letterIndex := 6
+	if match[letterIndex] == -1 {
+		letterIndex = 10
+	}
+	start, end := rawUrl[match[letterIndex]], rawUrl[match[letterIndex+2]]
+	if start > end {
+		return nil, fmt.Errorf("range [%c-%c] ends before it starts", start, end)
+	}
+	for letter := start; letter <= end; letter++ {
+		values = append(values, string(letter))
+	}
+	return values, nil
+}
+
+// ImportUrls gets the meta of the urls concurrently and adds their downloads to a new batch in the order of the urls.
+// headers are given for the source url when it is not empty so that their credentials are only sent to its host.
+// urls that fail are reported in their results. batch is nil when every url fails
+func (client *DirectDownloadEngine) ImportUrls(batchName string, sourceUrl string, urls []string, headers types.DownloadHeaders, proxyUrl string, savePath string, concurrency int, startDownload bool, addTopOfQueue bool, allowDuplicate bool) (*types.DownloadBatch, []types.BatchImportResult, error) {
+	if len(urls) == 0 {
+		return nil, nil, fmt.Errorf("no urls to import")
+	}
+	concurrency = max(concurrency, 1)
+
+	results := make([]types.BatchImportResult, len(urls))
+	files := make([]*remoteFile, len(urls))
+	semaphore := make(chan struct{}, concurrency)
+	var waitGroup sync.WaitGroup
+	for i, rawUrl := range urls {
+		results[i].Url = rawUrl
+		waitGroup.Add(1)
+		semaphore <- struct{}{}
+		go func() {
+			defer waitGroup.Done()
+			defer func() { <-semaphore }()
+			urlHeaders := headers
+			if sourceUrl != "" {
+				urlHeaders = headers.ForUrl(sourceUrl, rawUrl)
+			}
+			file, err := client.getRemoteFile(rawUrl, urlHeaders, proxyUrl, "")
+			if err != nil {
+				results[i].Error = err.Error()
+				return
+			}
+			files[i] = file
+		}()
+	}
+	waitGroup.Wait()
+
+	// batch is not created when no url can be downloaded
+	if !slices.ContainsFunc(files, func(file *remoteFile) bool { return file != nil }) {
+		return nil, results, nil
+	}
+	batch, err := client.CreateDownloadBatch(batchName, sourceUrl)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	order := make([]int, len(urls))
+	for i := range order {
+		order[i] = i
+	}
+	// downloads added to the top of the queue one by one would end up in reverse order
+	if addTopOfQueue {
+		slices.Reverse(order)
+	}
+	for _, i := range order {
+		if files[i] == nil {
+			continue
+		}
+		download, err := client.createDownload(files[i], DownloadOptions{
+			Proxy:          proxyUrl,
+			SavePath:       savePath,
+			AddTopOfQueue:  addTopOfQueue,
+			AllowDuplicate: allowDuplicate,
+		})
+		if err == nil {
+			results[i].Name = download.Name
+			results[i].DownloadId = download.Id
+			err = client.SetDownloadBatch(download.Id, batch.Id)
+		}
+		if err == nil && startDownload {
+			err = client.QueueDownload(download.Id)
+		}
+		if err != nil {
+			results[i].Error = err.Error()
+		}
+	}
+	return batch, results, nil
+}
@@ -0,0 +1,46 @@
+package direct
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestExpandUrlPatterns(t *testing.T) {
+	text := `
+# comment
+http://a/file[08-10].jpg
+http://a/img-[a-c]-[1-2].png
+http://[::1]/plain
+`
+	urls, err := ExpandUrlPatterns(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"http://a/file08.jpg", "http://a/file09.jpg", "http://a/file10.jpg",
+		"http://a/img-a-1.png", "http://a/img-a-2.png",
+		"http://a/img-b-1.png", "http://a/img-b-2.png",
+		"http://a/img-c-1.png", "http://a/img-c-2.png",
+		"http://[::1]/plain",
+	}
+	if !slices.Equal(urls, expected) {
+		t.Errorf("unexpected urls %v", urls)
+	}
+
+	urls, err = ExpandUrlPatterns("http://a/[1-10]")
+	if err != nil || urls[0] != "http://a/1" || urls[9] != "http://a/10" {
+		t.Errorf("numbers without leading zeros must not be padded, got %v %v", urls, err)
+	}
+
+	invalidTexts := []string{
+		"http://a/[10-1]",
+		"http://a/[z-a]",
+		"http://a/[0-99999]",
+		"http://a/[0-999]/[0-99]",
+	}
+	for _, invalidText := range invalidTexts {
+		if _, err := ExpandUrlPatterns(invalidText); err == nil {
+			t.Errorf("%s : expected error", invalidText)
+		}
+	}
+}
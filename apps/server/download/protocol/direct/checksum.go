@@ -0,0 +1,176 @@
+package direct
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"downite/types"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var checksumHashFuncs = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// ValidateChecksum checks that the algorithm is supported and the checksum is a hex string with the right length
+func ValidateChecksum(algorithm string, checksum string) error {
+	newHash, ok := checksumHashFuncs[strings.ToLower(algorithm)]
+	if !ok {
+		return fmt.Errorf("unsupported checksum algorithm : %s", algorithm)
+	}
+	decodedChecksum, err := hex.DecodeString(checksum)
+	if err != nil {
+		return fmt.Errorf("checksum is not a hex string : %s", err)
+	}
+	if len(decodedChecksum) != newHash().Size() {
+		return fmt.Errorf("checksum length does not match %s", algorithm)
+	}
+	return nil
+}
+
+// checksumHasher hashes the download file from its beginning while the parts are written.
+// only the bytes before the first missing byte can be hashed because parts finish in any order
+type checksumHasher struct {
+	hash   hash.Hash
+	offset int64
+}
+
+func newChecksumHasher(algorithm string) (*checksumHasher, error) {
+	newHash, ok := checksumHashFuncs[strings.ToLower(algorithm)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported checksum algorithm : %s", algorithm)
+	}
+	return &checksumHasher{hash: newHash()}, nil
+}
+
+// advance hashes the file from where it left up to end
+func (hasher *checksumHasher) advance(path string, end int64) error {
+	if end <= hasher.offset {
+		return nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	written, err := io.Copy(hasher.hash, io.NewSectionReader(file, hasher.offset, end-hasher.offset))
+	hasher.offset += written
+	if err != nil {
+		return err
+	}
+	if hasher.offset != end {
+		return fmt.Errorf("file ended at byte %d while hashing up to byte %d", hasher.offset, end)
+	}
+	return nil
+}
+
+func (hasher *checksumHasher) sum() string {
+	return hex.EncodeToString(hasher.hash.Sum(nil))
+}
+
+// downloadedPrefixLength returns how many bytes from the beginning of the download are written to the file
+func (client *DirectDownloadEngine) downloadedPrefixLength(download *types.Download) int64 {
+	client.mutexForDownloads.Lock()
+	defer client.mutexForDownloads.Unlock()
+
+	parts := make([]*types.DownloadPart, len(download.Parts))
+	copy(parts, download.Parts)
+	sort.Slice(parts, func(i, j int) bool {
+		return parts[i].StartByteIndex < parts[j].StartByteIndex
+	})
+
+	var prefixLength uint64
+	for _, part := range parts {
+		if part.StartByteIndex != prefixLength {
+			break
+		}
+		prefixLength += part.DownloadedBytes
+		if part.DownloadedBytes < part.PartLength {
+			break
+		}
+	}
+	return int64(prefixLength)
+}
+
+// compareChecksum returns an error describing the mismatch when the calculated checksum is not the expected one
+func compareChecksum(download *types.Download, calculatedChecksum string) error {
+	if !strings.EqualFold(calculatedChecksum, download.Checksum) {
+		return fmt.Errorf("checksum mismatch : expected %s %s but got %s", download.ChecksumAlgorithm, download.Checksum, calculatedChecksum)
+	}
+	return nil
+}
+
+// SetDownloadChecksum sets the checksum the download is verified against when it is completed
+func (client *DirectDownloadEngine) SetDownloadChecksum(id int, algorithm string, checksum string) error {
+	err := ValidateChecksum(algorithm, checksum)
+	if err != nil {
+		return err
+	}
+	download, err := client.GetDownload(id)
+	if err != nil {
+		return err
+	}
+
+	client.mutexForDownloads.Lock()
+	download.ChecksumAlgorithm = strings.ToLower(algorithm)
+	download.Checksum = strings.ToLower(checksum)
+	client.mutexForDownloads.Unlock()
+
+	return client.db.UpdateDownload(download)
+}
+
+// VerifyDownload hashes the whole file of a downloaded file and compares it with the expected checksum.
+// download goes to error state on mismatch and back to completed when it matches
+func (client *DirectDownloadEngine) VerifyDownload(id int) error {
+	download, err := client.GetDownload(id)
+	if err != nil {
+		return err
+	}
+	// fields are copied because running parts write them while the file is hashed
+	client.mutexForDownloads.Lock()
+	verified := *download
+	client.mutexForDownloads.Unlock()
+
+	if verified.Checksum == "" {
+		return fmt.Errorf("download has no checksum to verify")
+	}
+	if verified.DownloadedBytes != verified.TotalSize ||
+		(verified.Status != types.DownloadStatusCompleted.String() && verified.Status != types.DownloadStatusError.String()) {
+		return fmt.Errorf("download is not completed")
+	}
+
+	hasher, err := newChecksumHasher(verified.ChecksumAlgorithm)
+	if err != nil {
+		return err
+	}
+	err = hasher.advance(filepath.Join(verified.SavePath, verified.Name), int64(verified.TotalSize))
+	if err != nil {
+		return fmt.Errorf("while hashing download file : %s", err)
+	}
+
+	err = compareChecksum(&verified, hasher.sum())
+	if err != nil {
+		failErr := client.failDownload(id, err)
+		if failErr != nil {
+			return failErr
+		}
+		return err
+	}
+
+	client.mutexForDownloads.Lock()
+	download.Error = ""
+	client.mutexForDownloads.Unlock()
+	return client.updateDownloadStatus(id, types.DownloadStatusCompleted)
+}
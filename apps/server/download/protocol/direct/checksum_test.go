@@ -0,0 +1,60 @@
+package direct
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateChecksum(t *testing.T) {
+	tests := []struct {
+		algorithm string
+		checksum  string
+		isValid   bool
+	}{
+		{"md5", "d41d8cd98f00b204e9800998ecf8427e", true},
+		{"SHA256", "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", true},
+		{"sha1", "d41d8cd98f00b204e9800998ecf8427e", false},
+		{"sha256", "not a hex string", false},
+		{"crc32", "00000000", false},
+	}
+	for _, test := range tests {
+		err := ValidateChecksum(test.algorithm, test.checksum)
+		if (err == nil) != test.isValid {
+			t.Errorf("ValidateChecksum(%s, %s) = %v, want valid %t", test.algorithm, test.checksum, err, test.isValid)
+		}
+	}
+}
+
+func TestChecksumHasherAdvance(t *testing.T) {
+	data := []byte("downite checksum test data")
+	path := filepath.Join(t.TempDir(), "file")
+	err := os.WriteFile(path, data, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hasher, err := newChecksumHasher("sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// hashing in steps must give the same result as hashing at once
+	for _, end := range []int64{5, 5, 12, int64(len(data))} {
+		err = hasher.advance(path, end)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := sha256.Sum256(data)
+	if hasher.sum() != hex.EncodeToString(expected[:]) {
+		t.Errorf("checksum is %s, want %s", hasher.sum(), hex.EncodeToString(expected[:]))
+	}
+
+	err = hasher.advance(path, int64(len(data))+1)
+	if err == nil {
+		t.Errorf("expected error while hashing past the end of the file")
+	}
+}
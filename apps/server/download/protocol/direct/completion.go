@@ -0,0 +1,36 @@
+package direct
+
+import (
+	"downite/types"
+	"path/filepath"
+)
+
+// AddCompletionListener adds a listener that is called with every completed download
+func (client *DirectDownloadEngine) AddCompletionListener(listener func(event types.CompletionEvent)) {
+	client.mutexForListeners.Lock()
+	defer client.mutexForListeners.Unlock()
+	client.completionListeners = append(client.completionListeners, listener)
+}
+
+func (client *DirectDownloadEngine) emitCompletion(download *types.Download) {
+	client.mutexForDownloads.Lock()
+	event := types.CompletionEvent{
+		Kind:       types.TransferKindDownload.String(),
+		DownloadId: download.Id,
+		Name:       download.Name,
+		SavePath:   download.SavePath,
+		Path:       filepath.Join(download.SavePath, download.Name),
+		Url:        download.Url,
+		TotalSize:  download.TotalSize,
+		FinishedAt: download.FinishedAt.Time,
+	}
+	client.mutexForDownloads.Unlock()
+
+	client.mutexForListeners.Lock()
+	listeners := make([]func(event types.CompletionEvent), len(client.completionListeners))
+	copy(listeners, client.completionListeners)
+	client.mutexForListeners.Unlock()
+	for _, listener := range listeners {
+		listener(event)
+	}
+}
@@ -1,10 +1,19 @@
 package direct_test
 
 import (
+	"bytes"
+	"context"
 	"downite/db"
 	"downite/download/protocol/direct"
+	"downite/download/protocol/ftp/ftptest"
 	"downite/types"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 func initDownloadTest(t *testing.T) *direct.DirectDownloadEngine {
@@ -32,7 +41,7 @@ func initDownloadTest(t *testing.T) *direct.DirectDownloadEngine {
 
 func TestDownloadFromUrl(t *testing.T) {
 	client := initDownloadTest(t)
-	_, err := client.DownloadFromUrl("https://releases.ubuntu.com/24.04/ubuntu-24.04-desktop-amd64.iso", 8, "", true, false)
+	_, err := client.DownloadFromUrl("https://releases.ubuntu.com/24.04/ubuntu-24.04-desktop-amd64.iso", direct.DownloadOptions{PartCount: 8, StartDownload: true, AllowDuplicate: true})
 	if err != nil {
 		t.Errorf("Cannot create download : %s", err)
 	}
@@ -47,7 +56,7 @@ func addDownloadWithState(t *testing.T, client *direct.DirectDownloadEngine, sta
 		startDownload = false
 	}
 
-	download, err := client.DownloadFromUrl("https://releases.ubuntu.com/24.04/ubuntu-24.04-desktop-amd64.iso", 8, "", startDownload, false)
+	download, err := client.DownloadFromUrl("https://releases.ubuntu.com/24.04/ubuntu-24.04-desktop-amd64.iso", direct.DownloadOptions{PartCount: 8, StartDownload: startDownload, AllowDuplicate: true})
 	if err != nil {
 		t.Errorf("Cannot create download : %s", err)
 	}
@@ -77,3 +86,179 @@ func TestPauseDownloadIfStatePaused(t *testing.T) {
 		t.Errorf("Error when pausing already paused download : %s", err)
 	}
 }
+
+func TestDownloadFromUrlWithUnknownSize(t *testing.T) {
+	data := bytes.Repeat([]byte("downite unknown size "), 50000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		if r.Method == http.MethodHead {
+			return
+		}
+		// flushing before the end sends the body chunked without content length
+		for start := 0; start < len(data); start += 64 * 1024 {
+			w.Write(data[start:min(start+64*1024, len(data))])
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer server.Close()
+
+	client := initDownloadTest(t)
+	completed := make(chan types.CompletionEvent, 1)
+	client.AddCompletionListener(func(event types.CompletionEvent) {
+		completed <- event
+	})
+
+	download, err := client.DownloadFromUrl(server.URL+"/stream.bin", direct.DownloadOptions{PartCount: 8, SavePath: t.TempDir(), StartDownload: true, AllowDuplicate: true})
+	if err != nil {
+		t.Fatalf("Cannot create download : %s", err)
+	}
+	defer client.RemoveDownload(download.Id)
+	if !download.IsSizeUnknown || download.PartCount != 1 {
+		t.Errorf("expected a single part download of unknown size, got %d parts", download.PartCount)
+	}
+
+	select {
+	case event := <-completed:
+		if event.TotalSize != uint64(len(data)) {
+			t.Errorf("expected total size %d, got %d", len(data), event.TotalSize)
+		}
+		content, err := os.ReadFile(event.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(content, data) {
+			t.Errorf("downloaded file is not equal to the served file")
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatalf("download is not completed")
+	}
+}
+
+func TestDownloadFromFtpUrlWithCredentials(t *testing.T) {
+	data := bytes.Repeat([]byte("downite ftp "), 50000)
+	server := ftptest.NewServer(t, "/file.bin", data)
+
+	client := initDownloadTest(t)
+	completed := make(chan types.CompletionEvent, 1)
+	client.AddCompletionListener(func(event types.CompletionEvent) {
+		completed <- event
+	})
+
+	download, err := client.DownloadFromUrl(server.Url(), direct.DownloadOptions{PartCount: 4, SavePath: t.TempDir(), StartDownload: true, AllowDuplicate: true})
+	if err != nil {
+		t.Fatalf("Cannot create download : %s", err)
+	}
+	defer client.RemoveDownload(download.Id)
+	if strings.Contains(download.Url, "pass") {
+		t.Errorf("url of the download has the password : %s", download.Url)
+	}
+
+	select {
+	case event := <-completed:
+		content, err := os.ReadFile(event.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(content, data) {
+			t.Errorf("downloaded file is not equal to the served file")
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatalf("download is not completed")
+	}
+}
+
+// blockingResolver resolves pages of videos.example.com to the file url after it is released
+type blockingResolver struct {
+	fileUrl   string
+	resolving chan struct{}
+	release   chan struct{}
+}
+
+func (resolver *blockingResolver) Name() string {
+	return "blocking"
+}
+
+func (resolver *blockingResolver) CanResolve(parsedUrl *url.URL) bool {
+	return parsedUrl.Host == "videos.example.com"
+}
+
+func (resolver *blockingResolver) Resolve(ctx context.Context, httpClient *http.Client, rawUrl string, formatId string) ([]types.ResolvedFile, error) {
+	resolver.resolving <- struct{}{}
+	select {
+	case <-resolver.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return []types.ResolvedFile{{Url: resolver.fileUrl, Name: "video.mp4"}}, nil
+}
+
+func (resolver *blockingResolver) Formats(ctx context.Context, httpClient *http.Client, rawUrl string) ([]types.ResolvedFormat, error) {
+	return nil, nil
+}
+
+func TestQueueIsNotLockedWhileResolving(t *testing.T) {
+	data := bytes.Repeat([]byte("downite video "), 10000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "video.mp4", time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+
+	client := initDownloadTest(t)
+	resolver := &blockingResolver{fileUrl: server.URL + "/video.mp4", resolving: make(chan struct{}), release: make(chan struct{})}
+	client.Resolvers.Register(resolver)
+	completed := make(chan types.CompletionEvent, 1)
+	client.AddCompletionListener(func(event types.CompletionEvent) {
+		completed <- event
+	})
+
+	newPageDownload := func(pageUrl string) *types.Download {
+		download, err := client.DownloadFromPage(pageUrl, direct.DownloadOptions{Name: "video.mp4", PartCount: 2, SavePath: t.TempDir(), AllowDuplicate: true})
+		if err != nil {
+			t.Fatalf("Cannot create download : %s", err)
+		}
+		t.Cleanup(func() {
+			client.RemoveDownload(download.Id)
+		})
+		go client.QueueDownload(download.Id)
+		<-resolver.resolving
+		return download
+	}
+
+	// download paused while its page is resolved stays paused
+	pausedDownload := newPageDownload("https://videos.example.com/watch/1")
+	err := client.PauseDownload(pausedDownload.Id)
+	if err != nil {
+		t.Fatalf("Cannot pause download while resolving : %s", err)
+	}
+	resolver.release <- struct{}{}
+
+	download := newPageDownload("https://videos.example.com/watch/2")
+	done := make(chan struct{})
+	go func() {
+		client.IsQueueActive()
+		client.GetMaxConcurrentDownloads()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("queue is locked while the page is resolved")
+	}
+	err = client.ForceStartDownload(download.Id)
+	if err == nil {
+		t.Errorf("expected error when force starting a download that is starting")
+	}
+	resolver.release <- struct{}{}
+
+	select {
+	case event := <-completed:
+		if event.DownloadId != download.Id {
+			t.Errorf("expected download %d to complete, got %d", download.Id, event.DownloadId)
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatalf("download is not completed")
+	}
+	if !client.CheckDownloadStatus(pausedDownload.Id, types.DownloadStatusPaused) {
+		t.Errorf("download paused while resolving is started")
+	}
+}
@@ -0,0 +1,91 @@
+package direct
+
+import (
+	"downite/types"
+	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// DuplicateDownloadError is returned when a download of the same file already exists
+type DuplicateDownloadError struct {
+	Download *types.Download
+	// url, path or size
+	Reason string
+}
+
+func (err *DuplicateDownloadError) Error() string {
+	return fmt.Sprintf("download already exists with the same %s : %s", err.Reason, err.Download.Name)
+}
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ftp":   "21",
+}
+
+// normalizeUrl returns the url in a form that is the same for the urls of the same file. scheme and host are lowercased,
+// default ports, credentials and fragments are removed and query parameters are sorted
+func normalizeUrl(rawUrl string) string {
+	parsedUrl, err := url.Parse(strings.TrimSpace(rawUrl))
+	if err != nil || parsedUrl.Host == "" {
+		return rawUrl
+	}
+	parsedUrl.Scheme = strings.ToLower(parsedUrl.Scheme)
+	host := strings.ToLower(parsedUrl.Hostname())
+	port := parsedUrl.Port()
+	if port != "" && port != defaultPorts[parsedUrl.Scheme] {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		// ipv6 addresses keep their brackets
+		host = "[" + host + "]"
+	}
+	parsedUrl.Host = host
+	// credentials are not a part of the file
+	parsedUrl.User = nil
+	parsedUrl.Fragment = ""
+	parsedUrl.RawFragment = ""
+	if parsedUrl.Path == "" {
+		parsedUrl.Path = "/"
+		parsedUrl.RawPath = ""
+	}
+	if parsedUrl.RawQuery != "" {
+		parsedUrl.RawQuery = parsedUrl.Query().Encode()
+	}
+	return parsedUrl.String()
+}
+
+// CheckDownload returns the existing download of the same file and what they have in common.
+// downloads match by the normalized url of the download, its page or its mirrors, by the same file path and by the same name with the same size
+func (client *DirectDownloadEngine) CheckDownload(rawUrl string, fileName string, savePath string, fileSize uint64) (*types.Download, string) {
+	client.mutexForDownloads.Lock()
+	defer client.mutexForDownloads.Unlock()
+
+	normalizedUrl := normalizeUrl(rawUrl)
+	for _, download := range client.downloads {
+		if normalizeUrl(download.Url) == normalizedUrl || (download.PageUrl != "" && normalizeUrl(download.PageUrl) == normalizedUrl) {
+			return download, "url"
+		}
+		for _, mirror := range download.Mirrors {
+			if normalizeUrl(mirror.Url) == normalizedUrl {
+				return download, "url"
+			}
+		}
+	}
+	for _, download := range client.downloads {
+		if download.Name == fileName && filepath.Clean(download.SavePath) == filepath.Clean(savePath) {
+			return download, "path"
+		}
+	}
+	if fileSize == 0 {
+		return nil, ""
+	}
+	for _, download := range client.downloads {
+		if download.Name == fileName && download.TotalSize == fileSize {
+			return download, "size"
+		}
+	}
+	return nil, ""
+}
@@ -0,0 +1,60 @@
+package direct
+
+import (
+	"downite/types"
+	"testing"
+)
+
+func TestNormalizeUrl(t *testing.T) {
+	testCases := []struct {
+		a    string
+		b    string
+		same bool
+	}{
+		{"HTTP://Example.COM:80/file.zip", "http://example.com/file.zip", true},
+		{"https://example.com/file.zip?b=2&a=1#part", "https://example.com/file.zip?a=1&b=2", true},
+		{"https://example.com", "https://example.com/", true},
+		{"https://example.com:8443/file.zip", "https://example.com/file.zip", false},
+		{"https://example.com/File.zip", "https://example.com/file.zip", false},
+	}
+	for _, testCase := range testCases {
+		if (normalizeUrl(testCase.a) == normalizeUrl(testCase.b)) != testCase.same {
+			t.Errorf("expected %s and %s to be same %t, got %s and %s", testCase.a, testCase.b, testCase.same, normalizeUrl(testCase.a), normalizeUrl(testCase.b))
+		}
+	}
+}
+
+func TestCheckDownload(t *testing.T) {
+	client := &DirectDownloadEngine{
+		downloads: map[int]*types.Download{
+			1: {
+				Id:        1,
+				Url:       "https://example.com/file.zip",
+				Name:      "file.zip",
+				SavePath:  "/downloads",
+				TotalSize: 1000,
+				Mirrors:   []*types.DownloadMirror{{Url: "https://mirror.example.com/file.zip"}},
+			},
+		},
+	}
+	testCases := []struct {
+		url      string
+		name     string
+		savePath string
+		size     uint64
+		reason   string
+	}{
+		{"https://EXAMPLE.com/file.zip#x", "other.zip", "/other", 0, "url"},
+		{"https://mirror.example.com/file.zip", "other.zip", "/other", 0, "url"},
+		{"https://other.com/file.zip", "file.zip", "/downloads/", 0, "path"},
+		{"https://other.com/file.zip", "file.zip", "/other", 1000, "size"},
+		{"https://other.com/file.zip", "file.zip", "/other", 2000, ""},
+		{"https://other.com/file.zip", "file.zip", "/other", 0, ""},
+	}
+	for _, testCase := range testCases {
+		download, reason := client.CheckDownload(testCase.url, testCase.name, testCase.savePath, testCase.size)
+		if reason != testCase.reason || (reason != "") != (download != nil) {
+			t.Errorf("%s %s : expected reason %q, got %q", testCase.url, testCase.savePath, testCase.reason, reason)
+		}
+	}
+}
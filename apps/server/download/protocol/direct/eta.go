@@ -0,0 +1,63 @@
+package direct
+
+import (
+	"downite/types"
+	"math"
+	"time"
+)
+
+// weight of the last second in the smoothed speed. lower values react slower to speed changes
+const speedSmoothingFactor = 0.3
+
+// smoothSpeed returns the exponentially smoothed speed with the speed of the last second
+func smoothSpeed(smoothedSpeed float64, speed float64) float64 {
+	if smoothedSpeed == 0 {
+		return speed
+	}
+	return speedSmoothingFactor*speed + (1-speedSmoothingFactor)*smoothedSpeed
+}
+
+// estimateEta returns the seconds left to download the remaining bytes with the speed in KB/s. -1 means unknown
+func estimateEta(remainingBytes uint64, speed float64) int64 {
+	if remainingBytes == 0 {
+		return 0
+	}
+	if speed < 1 {
+		return -1
+	}
+	return int64(math.Ceil(float64(remainingBytes) / (speed * 1024)))
+}
+
+// updateDownloadTimes adds the elapsed time to active times and updates smoothed speeds and etas. download speeds must be updated before
+func updateDownloadTimes(download *types.Download, elapsed time.Duration) {
+	if download.Status != types.DownloadStatusDownloading.String() {
+		download.SmoothedSpeed = 0
+		download.Eta = -1
+		for _, part := range download.Parts {
+			part.SmoothedSpeed = 0
+			part.Eta = -1
+		}
+		return
+	}
+
+	download.TimeActive += elapsed
+	download.SmoothedSpeed = smoothSpeed(download.SmoothedSpeed, float64(download.DownloadSpeed))
+	download.Eta = -1
+	if !download.IsSizeUnknown && download.TotalSize != 0 && download.TotalSize >= download.DownloadedBytes {
+		download.Eta = estimateEta(download.TotalSize-download.DownloadedBytes, download.SmoothedSpeed)
+	}
+
+	for _, part := range download.Parts {
+		if part.Status != types.DownloadStatusDownloading.String() {
+			part.SmoothedSpeed = 0
+			part.Eta = -1
+			continue
+		}
+		part.TimeActive += elapsed
+		part.SmoothedSpeed = smoothSpeed(part.SmoothedSpeed, float64(part.DownloadSpeed))
+		part.Eta = -1
+		if part.PartLength != 0 && part.PartLength >= part.DownloadedBytes {
+			part.Eta = estimateEta(part.PartLength-part.DownloadedBytes, part.SmoothedSpeed)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package direct
+
+import (
+	"downite/types"
+	"testing"
+	"time"
+)
+
+func TestUpdateDownloadTimes(t *testing.T) {
+	part := &types.DownloadPart{
+		Status:          types.DownloadStatusDownloading.String(),
+		PartLength:      100 * 1024,
+		DownloadedBytes: 40 * 1024,
+		DownloadSpeed:   10,
+	}
+	completedPart := &types.DownloadPart{
+		Status:     types.DownloadStatusCompleted.String(),
+		PartLength: 100 * 1024,
+	}
+	download := &types.Download{
+		Status:          types.DownloadStatusDownloading.String(),
+		TotalSize:       200 * 1024,
+		DownloadedBytes: 140 * 1024,
+		DownloadSpeed:   10,
+		Parts:           []*types.DownloadPart{part, completedPart},
+	}
+
+	updateDownloadTimes(download, time.Second)
+	if download.TimeActive != time.Second || part.TimeActive != time.Second || completedPart.TimeActive != 0 {
+		t.Errorf("only running downloads and parts must be active, got %s %s %s", download.TimeActive, part.TimeActive, completedPart.TimeActive)
+	}
+	if download.SmoothedSpeed != 10 || download.Eta != 6 || part.Eta != 6 || completedPart.Eta != -1 {
+		t.Errorf("unexpected speed %f and etas %d %d %d", download.SmoothedSpeed, download.Eta, part.Eta, completedPart.Eta)
+	}
+
+	// smoothed speed follows the speed slowly
+	download.DownloadSpeed = 20
+	updateDownloadTimes(download, time.Second)
+	if download.SmoothedSpeed != 13 || download.Eta != 5 {
+		t.Errorf("expected smoothed speed 13 and eta 5, got %f %d", download.SmoothedSpeed, download.Eta)
+	}
+
+	download.Status = types.DownloadStatusPaused.String()
+	updateDownloadTimes(download, time.Second)
+	if download.TimeActive != 2*time.Second || download.SmoothedSpeed != 0 || download.Eta != -1 {
+		t.Errorf("paused downloads must not be active, got %s %f %d", download.TimeActive, download.SmoothedSpeed, download.Eta)
+	}
+
+	download.Status = types.DownloadStatusDownloading.String()
+	download.IsSizeUnknown = true
+	updateDownloadTimes(download, time.Second)
+	if download.Eta != -1 {
+		t.Errorf("eta of files of unknown size can't be estimated, got %d", download.Eta)
+	}
+}
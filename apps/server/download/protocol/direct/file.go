@@ -0,0 +1,136 @@
+package direct
+
+import (
+	"downite/types"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// openDownloadFile opens the target file of the download for writing parts at their offsets
+func openDownloadFile(download *types.Download) (*os.File, error) {
+	return os.OpenFile(filepath.Join(download.SavePath, download.Name), os.O_CREATE|os.O_WRONLY, 0644)
+}
+
+// prepareDownloadFile creates the target file with its final size so that every part can write to its own offset
+func prepareDownloadFile(download *types.Download) error {
+	file, err := openDownloadFile(download)
+	if err != nil {
+		return fmt.Errorf("while creating download file : %s", err)
+	}
+	defer file.Close()
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if uint64(fileInfo.Size()) != download.TotalSize {
+		err = preallocateFile(file, int64(download.TotalSize))
+		if err != nil {
+			return fmt.Errorf("while preallocating download file : %s", err)
+		}
+	}
+
+	return migratePartFiles(download, file)
+}
+
+func partFilePath(download *types.Download, part *types.DownloadPart) string {
+	return filepath.Join(download.SavePath, fmt.Sprintf("%s_part%d", download.Name, part.PartIndex))
+}
+
+// migratePartFiles moves the data of downloads started by older versions from their _partN files into the target file
+func migratePartFiles(download *types.Download, file *os.File) error {
+	for _, part := range download.Parts {
+		partFile, err := os.Open(partFilePath(download, part))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+
+		fmt.Printf("moving part file into download file : %s_part%d \n", download.Name, part.PartIndex)
+		_, err = io.Copy(io.NewOffsetWriter(file, int64(part.StartByteIndex)), io.LimitReader(partFile, int64(part.DownloadedBytes)))
+		partFile.Close()
+		if err != nil {
+			return err
+		}
+
+		err = os.Remove(partFilePath(download, part))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MoveDownload moves the file of a completed download into the directory and saves its new path
+func (client *DirectDownloadEngine) MoveDownload(id int, savePath string) error {
+	download, err := client.GetDownload(id)
+	if err != nil {
+		return err
+	}
+	client.mutexForDownloads.Lock()
+	if download.Status != types.DownloadStatusCompleted.String() {
+		client.mutexForDownloads.Unlock()
+		return fmt.Errorf("only completed downloads can be moved")
+	}
+	oldPath := filepath.Join(download.SavePath, download.Name)
+	newPath := filepath.Join(savePath, download.Name)
+	client.mutexForDownloads.Unlock()
+
+	if oldPath == newPath {
+		return nil
+	}
+	err = os.MkdirAll(savePath, 0755)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stat(newPath)
+	if err == nil {
+		return fmt.Errorf("file already exists : %s", newPath)
+	}
+	err = moveFile(oldPath, newPath)
+	if err != nil {
+		return fmt.Errorf("while moving download file : %s", err)
+	}
+
+	client.mutexForDownloads.Lock()
+	download.SavePath = savePath
+	client.mutexForDownloads.Unlock()
+	return client.db.UpdateDownload(download)
+}
+
+// moveFile renames the file. it is copied when the directories are on different devices
+func moveFile(oldPath string, newPath string) error {
+	err := os.Rename(oldPath, newPath)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	source, err := os.Open(oldPath)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	target, err := os.OpenFile(newPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(target, source)
+	if closeErr := target.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(newPath)
+		return err
+	}
+	source.Close()
+	return os.Remove(oldPath)
+}
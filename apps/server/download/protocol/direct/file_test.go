@@ -0,0 +1,102 @@
+package direct
+
+import (
+	"bytes"
+	"downite/types"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPrepareDownloadFile(t *testing.T) {
+	data := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	testCases := []struct {
+		name      string
+		partCount int
+		// bytes of every part written to its _partN file by older versions. -1 means the part file doesn't exist
+		partFileBytes []int
+	}{
+		{"no part files", 4, []int{-1, -1, -1, -1}},
+		{"all parts completed", 4, []int{9, 9, 9, 9}},
+		{"parts partially downloaded", 4, []int{3, 0, 9, 5}},
+		{"some part files missing", 3, []int{12, -1, 7}},
+		{"last part gets the rest", 5, []int{7, 7, 7, 7, 8}},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			download := &types.Download{
+				Name:       "file.bin",
+				SavePath:   t.TempDir(),
+				TotalSize:  uint64(len(data)),
+				PartCount:  testCase.partCount,
+				PartLength: uint64(len(data) / testCase.partCount),
+				Parts:      make([]*types.DownloadPart, testCase.partCount),
+			}
+			createDownloadParts(download)
+
+			expected := make([]byte, len(data))
+			for i, part := range download.Parts {
+				if testCase.partFileBytes[i] == -1 {
+					continue
+				}
+				part.DownloadedBytes = uint64(testCase.partFileBytes[i])
+				partData := data[part.StartByteIndex : part.StartByteIndex+part.PartLength]
+				// part files may have more bytes than saved to db when the app was closed while writing
+				err := os.WriteFile(partFilePath(download, part), partData, 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+				copy(expected[part.StartByteIndex:], partData[:part.DownloadedBytes])
+			}
+
+			err := prepareDownloadFile(download)
+			if err != nil {
+				t.Fatalf("cannot prepare download file : %s", err)
+			}
+
+			content, err := os.ReadFile(filepath.Join(download.SavePath, download.Name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(content, expected) {
+				t.Errorf("expected %q, got %q", expected, content)
+			}
+			for _, part := range download.Parts {
+				_, err := os.Stat(partFilePath(download, part))
+				if !os.IsNotExist(err) {
+					t.Errorf("part file of part %d is not removed", part.PartIndex)
+				}
+			}
+		})
+	}
+}
+
+func TestPrepareDownloadFileKeepsWrittenBytes(t *testing.T) {
+	download := &types.Download{
+		Name:       "file.bin",
+		SavePath:   t.TempDir(),
+		TotalSize:  8,
+		PartCount:  2,
+		PartLength: 4,
+		Parts:      make([]*types.DownloadPart, 2),
+	}
+	createDownloadParts(download)
+	path := filepath.Join(download.SavePath, download.Name)
+	err := os.WriteFile(path, []byte("abcdefgh"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// file of a resumed download already has its final size
+	err = prepareDownloadFile(download)
+	if err != nil {
+		t.Fatalf("cannot prepare download file : %s", err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "abcdefgh" {
+		t.Errorf("bytes of the resumed download are changed : %q", content)
+	}
+}
@@ -0,0 +1,431 @@
+package direct
+
+import (
+	"context"
+	"database/sql"
+	"downite/download/protocol/hls"
+	"downite/types"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// playlists and keys are small. anything bigger is not a playlist
+const maxHlsPlaylistSize = 10 * 1024 * 1024
+
+// openHttpResource gets the url with the headers of the download. length is the size of the byte range starting at offset, 0 means the whole resource.
+// validator is optional. it checks that the range belongs to the same file
+func (client *DirectDownloadEngine) openHttpResource(ctx context.Context, rawUrl string, headers types.DownloadHeaders, proxyUrl string, offset int64, length int64, validator *rangeValidator) (io.ReadCloser, error) {
+	httpClient, err := client.getHttpClient(proxyUrl)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", rawUrl, nil)
+	if err != nil {
+		return nil, fmt.Errorf("while creating request: %s", err)
+	}
+	headers.Apply(req)
+	if length > 0 {
+		req.Header.Add("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+		if validator != nil && validator.ifRange() != "" {
+			req.Header.Set("If-Range", validator.ifRange())
+		}
+	}
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("while download : %w", err)
+	}
+	if res.StatusCode != http.StatusPartialContent && res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, &statusCodeError{StatusCode: res.StatusCode, Status: res.Status}
+	}
+	if length > 0 && validator != nil {
+		err = validator.check(res)
+		if err != nil {
+			res.Body.Close()
+			return nil, err
+		}
+	}
+	if length > 0 && res.StatusCode != http.StatusPartialContent {
+		res.Body.Close()
+		return nil, fmt.Errorf("server ignored the range request")
+	}
+	return res.Body, nil
+}
+
+func (client *DirectDownloadEngine) fetchHlsPlaylist(ctx context.Context, rawUrl string, headers types.DownloadHeaders, proxyUrl string) (*hls.Playlist, error) {
+	body, err := client.openHttpResource(ctx, rawUrl, headers, proxyUrl, 0, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return hls.ParsePlaylist(io.LimitReader(body, maxHlsPlaylistSize), rawUrl)
+}
+
+// fetchHlsMediaPlaylist returns the media playlist of the url and its url. the variant with the highest bandwidth is used for master playlists.
+// other variants are chosen by downloading their urls from the variants of the meta
+func (client *DirectDownloadEngine) fetchHlsMediaPlaylist(ctx context.Context, rawUrl string, headers types.DownloadHeaders, proxyUrl string) (*hls.Playlist, string, error) {
+	playlist, err := client.fetchHlsPlaylist(ctx, rawUrl, headers, proxyUrl)
+	if err != nil {
+		return nil, "", err
+	}
+	mediaUrl := rawUrl
+	if playlist.Media == nil {
+		mediaUrl = hls.BestVariant(playlist.Variants).Url
+		mediaPlaylist, err := client.fetchHlsPlaylist(ctx, mediaUrl, headers.ForUrl(rawUrl, mediaUrl), proxyUrl)
+		if err != nil {
+			return nil, "", fmt.Errorf("while getting variant playlist : %s", err)
+		}
+		if mediaPlaylist.Media == nil {
+			return nil, "", fmt.Errorf("variant playlist is not a media playlist")
+		}
+		playlist.Media = mediaPlaylist.Media
+	}
+	if playlist.Media.IsLive {
+		return nil, "", fmt.Errorf("live hls streams are not supported")
+	}
+	return playlist, mediaUrl, nil
+}
+
+// getHlsMeta lists the variants of the stream. url of the meta is the media playlist that is downloaded
+func (client *DirectDownloadEngine) getHlsMeta(rawUrl string, headers types.DownloadHeaders, proxyUrl string) (*types.DownloadMeta, error) {
+	fileName, err := hls.FileName(rawUrl)
+	if err != nil {
+		return nil, err
+	}
+	playlist, mediaUrl, err := client.fetchHlsMediaPlaylist(context.Background(), rawUrl, headers, proxyUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.DownloadMeta{
+		FileName:       fileName,
+		Url:            mediaUrl,
+		FileType:       ".ts",
+		IsRangeAllowed: true,
+		IsHls:          true,
+		Variants:       playlist.Variants,
+		SegmentCount:   len(playlist.Media.Segments),
+		Duration:       playlist.Media.Duration,
+	}, nil
+}
+
+// hlsSegmentDir keeps the downloaded segments until they are joined into the download file
+func hlsSegmentDir(download *types.Download) string {
+	return filepath.Join(download.SavePath, fmt.Sprintf(".%s_segments", download.Name))
+}
+
+func hlsSegmentPath(segmentDir string, segment *hls.Segment) string {
+	return filepath.Join(segmentDir, "segment"+strconv.Itoa(segment.Index))
+}
+
+// hlsKeyCache fetches every key of a stream only once
+type hlsKeyCache struct {
+	mutex sync.Mutex
+	keys  map[string][]byte
+}
+
+func (keyCache *hlsKeyCache) get(ctx context.Context, client *DirectDownloadEngine, download *types.Download, keyUrl string) ([]byte, error) {
+	keyCache.mutex.Lock()
+	defer keyCache.mutex.Unlock()
+
+	if key, ok := keyCache.keys[keyUrl]; ok {
+		return key, nil
+	}
+	body, err := client.openHttpResource(ctx, keyUrl, download.Headers.ForUrl(download.Url, keyUrl), download.Proxy, 0, 0, nil)
+	if err != nil {
+		return nil, fmt.Errorf("while getting key : %w", err)
+	}
+	defer body.Close()
+	key, err := io.ReadAll(io.LimitReader(body, maxHlsPlaylistSize))
+	if err != nil {
+		return nil, fmt.Errorf("while getting key : %w", err)
+	}
+	if len(key) != 16 {
+		return nil, fmt.Errorf("key of %s is %d bytes instead of 16", keyUrl, len(key))
+	}
+	keyCache.keys[keyUrl] = key
+	return key, nil
+}
+
+// segmentProgressWriter counts the bytes of a segment in its download
+type segmentProgressWriter struct {
+	client   *DirectDownloadEngine
+	download *types.Download
+	written  uint64
+}
+
+func (progressWriter *segmentProgressWriter) Write(bytes []byte) (int, error) {
+	progressWriter.client.mutexForDownloads.Lock()
+	defer progressWriter.client.mutexForDownloads.Unlock()
+
+	progressWriter.written += uint64(len(bytes))
+	return progressWriter.download.Write(bytes)
+}
+
+// downloadHlsSegment downloads, decrypts and saves a single segment. segment file only exists when the segment is completed
+func (client *DirectDownloadEngine) downloadHlsSegment(ctx context.Context, download *types.Download, segment *hls.Segment, segmentDir string, keyCache *hlsKeyCache) error {
+	var key []byte
+	var err error
+	if segment.Key != nil {
+		key, err = keyCache.get(ctx, client, download, segment.Key.Url)
+		if err != nil {
+			return err
+		}
+	}
+
+	limiters, err := client.getLimiters(download, segment.Url)
+	if err != nil {
+		return err
+	}
+	release, err := client.hostLimits.acquire(ctx, segment.Url)
+	if err != nil {
+		return err
+	}
+	defer release()
+	body, err := client.openHttpResource(ctx, segment.Url, download.Headers.ForUrl(download.Url, segment.Url), download.Proxy, segment.Offset, segment.Length, nil)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	progressWriter := &segmentProgressWriter{client: client, download: download}
+	data, err := io.ReadAll(io.TeeReader(newLimitedReader(ctx, body, limiters...), progressWriter))
+	if err == nil && segment.Length > 0 && int64(len(data)) != segment.Length {
+		err = errPartIncomplete
+	}
+	if err == nil && key != nil {
+		data, err = hls.DecryptSegment(data, key, segment.IV())
+	}
+	if err == nil {
+		segmentPath := hlsSegmentPath(segmentDir, segment)
+		err = os.WriteFile(segmentPath+".tmp", data, 0644)
+		if err == nil {
+			err = os.Rename(segmentPath+".tmp", segmentPath)
+		}
+	}
+
+	client.mutexForDownloads.Lock()
+	defer client.mutexForDownloads.Unlock()
+	// failed segments start from zero. decrypted segments are a bit smaller than what is downloaded
+	download.DownloadedBytes -= progressWriter.written
+	if err != nil {
+		download.UpdateProgress()
+		return fmt.Errorf("while downloading segment %d : %w", segment.Index, err)
+	}
+	download.DownloadedBytes += uint64(len(data))
+	download.CompletedSegmentCount++
+	download.UpdateProgress()
+	return nil
+}
+
+// downloadHlsStream downloads the missing segments with PartCount connections and joins them into the download file
+func (client *DirectDownloadEngine) downloadHlsStream(ctx context.Context, download *types.Download) error {
+	var playlist *hls.Playlist
+	err := client.retryWithBackoff(ctx, "playlist of "+download.Name, func() error {
+		var err error
+		playlist, err = client.fetchHlsPlaylist(ctx, download.Url, download.Headers, download.Proxy)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if playlist.Media == nil || playlist.Media.IsLive {
+		return fmt.Errorf("%s is not a finished media playlist", download.Url)
+	}
+	segments := playlist.Media.Segments
+
+	segmentDir := hlsSegmentDir(download)
+	err = os.MkdirAll(segmentDir, 0755)
+	if err != nil {
+		return fmt.Errorf("while creating segment directory : %s", err)
+	}
+
+	// segments completed before are kept in the segment directory
+	pendingSegments := make([]*hls.Segment, 0, len(segments))
+	var downloadedBytes uint64
+	for _, segment := range segments {
+		segmentInfo, err := os.Stat(hlsSegmentPath(segmentDir, segment))
+		if err != nil {
+			pendingSegments = append(pendingSegments, segment)
+			continue
+		}
+		downloadedBytes += uint64(segmentInfo.Size())
+	}
+	client.mutexForDownloads.Lock()
+	download.SegmentCount = len(segments)
+	download.CompletedSegmentCount = len(segments) - len(pendingSegments)
+	download.DownloadedBytes = downloadedBytes
+	download.UpdateProgress()
+	connectionCount := download.PartCount
+	client.mutexForDownloads.Unlock()
+
+	if connectionCount < 1 {
+		connectionCount = 1
+	}
+	if connectionCount > len(pendingSegments) {
+		connectionCount = len(pendingSegments)
+	}
+
+	workerCtx, cancelWorkers := context.WithCancelCause(ctx)
+	defer cancelWorkers(nil)
+	keyCache := &hlsKeyCache{keys: make(map[string][]byte)}
+	segmentChan := make(chan *hls.Segment)
+	var waitGroup sync.WaitGroup
+	for i := 0; i < connectionCount; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			for segment := range segmentChan {
+				err := client.retryWithBackoff(workerCtx, fmt.Sprintf("segment %d of %s", segment.Index, download.Name), func() error {
+					return client.downloadHlsSegment(workerCtx, download, segment, segmentDir, keyCache)
+				})
+				if err != nil {
+					cancelWorkers(err)
+					return
+				}
+			}
+		}()
+	}
+
+sendSegments:
+	for _, segment := range pendingSegments {
+		select {
+		case segmentChan <- segment:
+		case <-workerCtx.Done():
+			break sendSegments
+		}
+	}
+	close(segmentChan)
+	waitGroup.Wait()
+
+	if ctx.Err() != nil {
+		return context.Canceled
+	}
+	if err := context.Cause(workerCtx); err != nil {
+		return err
+	}
+
+	return client.joinHlsSegments(download, segments, segmentDir)
+}
+
+// joinHlsSegments writes the segments into the download file in order and removes the segment directory
+func (client *DirectDownloadEngine) joinHlsSegments(download *types.Download, segments []*hls.Segment, segmentDir string) error {
+	filePath := filepath.Join(download.SavePath, download.Name)
+	file, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("while creating download file : %s", err)
+	}
+	defer file.Close()
+
+	var totalSize int64
+	for _, segment := range segments {
+		segmentFile, err := os.Open(hlsSegmentPath(segmentDir, segment))
+		if err != nil {
+			return fmt.Errorf("while joining segments : %s", err)
+		}
+		written, err := io.Copy(file, segmentFile)
+		segmentFile.Close()
+		if err != nil {
+			return fmt.Errorf("while joining segments : %s", err)
+		}
+		totalSize += written
+	}
+	err = file.Sync()
+	if err != nil {
+		return err
+	}
+
+	client.mutexForDownloads.Lock()
+	download.TotalSize = uint64(totalSize)
+	download.DownloadedBytes = uint64(totalSize)
+	client.mutexForDownloads.Unlock()
+
+	if download.Checksum != "" {
+		fmt.Printf("verifying checksum : %s \n", filePath)
+		hasher, err := newChecksumHasher(download.ChecksumAlgorithm)
+		if err != nil {
+			return err
+		}
+		err = hasher.advance(filePath, totalSize)
+		if err != nil {
+			return err
+		}
+		err = compareChecksum(download, hasher.sum())
+		if err != nil {
+			return err
+		}
+	}
+
+	return os.RemoveAll(segmentDir)
+}
+
+// startHlsDownload runs the download of an hls stream in a new goroutine. stream is stopped like the parts of other downloads
+func (client *DirectDownloadEngine) startHlsDownload(download *types.Download) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	client.mutexForPartContexts.Lock()
+	client.partContextMap[download.Id] = []*contextWithCancel{{ctx: &ctx, cancel: cancel}}
+	client.mutexForPartContexts.Unlock()
+
+	err := client.updateDownloadStatus(download.Id, types.DownloadStatusDownloading)
+	if err != nil {
+		cancel()
+		return err
+	}
+	client.mutexForDownloads.Lock()
+	download.StartedAt = sql.NullTime{
+		Time:  time.Now(),
+		Valid: true,
+	}
+	err = client.db.UpdateDownload(download)
+	client.mutexForDownloads.Unlock()
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	go func() {
+		// whatever happens to this download, the next one in the queue may start
+		defer client.processQueue()
+
+		err := client.downloadHlsStream(ctx, download)
+		// download is paused or put back to queue. status is already updated
+		if errors.Is(err, context.Canceled) || ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			fmt.Printf("Error while downloading hls stream %s : %s \n", download.Name, err)
+			err = client.failDownload(download.Id, err)
+			if err != nil {
+				fmt.Printf("Error while updating download status in db : %s \n", err)
+			}
+			return
+		}
+
+		client.mutexForPartContexts.Lock()
+		delete(client.partContextMap, download.Id)
+		client.mutexForPartContexts.Unlock()
+
+		client.mutexForDownloads.Lock()
+		download.FinishedAt = sql.NullTime{
+			Time:  time.Now(),
+			Valid: true,
+		}
+		client.mutexForDownloads.Unlock()
+		err = client.updateDownloadStatus(download.Id, types.DownloadStatusCompleted)
+		if err != nil {
+			fmt.Printf("Error while updating download status in db : %s", err)
+			return
+		}
+		fmt.Printf("download completed : %s \n", filepath.Join(download.SavePath, download.Name))
+		client.emitCompletion(download)
+	}()
+	return nil
+}
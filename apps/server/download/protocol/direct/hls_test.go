@@ -0,0 +1,84 @@
+package direct
+
+import (
+	"context"
+	"downite/download/protocol/hls"
+	"downite/types"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestHlsCredentialsAreNotSentToOtherHosts(t *testing.T) {
+	var mutex sync.Mutex
+	// credentials every path got. paths of the other host start with /other
+	credentials := make(map[string]string)
+	handler := func(w http.ResponseWriter, r *http.Request, body string) {
+		mutex.Lock()
+		credentials[r.URL.Path] = r.Header.Get("Cookie") + r.Header.Get("Authorization")
+		mutex.Unlock()
+		fmt.Fprint(w, body)
+	}
+	otherServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/other/variant.m3u8":
+			handler(w, r, "#EXTM3U\n#EXT-X-TARGETDURATION:10\n#EXTINF:10,\nsegment.ts\n#EXT-X-ENDLIST\n")
+		case "/other/key":
+			handler(w, r, "0123456789abcdef")
+		default:
+			handler(w, r, "segment data")
+		}
+	}))
+	defer otherServer.Close()
+	// same server on another host name
+	otherUrl := strings.Replace(otherServer.URL, "127.0.0.1", "localhost", 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler(w, r, "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1000\n"+otherUrl+"/other/variant.m3u8\n")
+	}))
+	defer server.Close()
+
+	client, err := CreateDownloadClient(&DownloadClientConfig{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers := types.DownloadHeaders{"Cookie": "session=secret", "Authorization": "Bearer secret"}
+	ctx := context.Background()
+
+	_, mediaUrl, err := client.fetchHlsMediaPlaylist(ctx, server.URL+"/master.m3u8", headers, "")
+	if err != nil {
+		t.Fatalf("cannot get media playlist : %s", err)
+	}
+	download := &types.Download{Id: 1, Url: server.URL + "/master.m3u8", Headers: headers}
+	client.downloadLimiters[download.Id] = newSpeedLimiter(0)
+	keyCache := &hlsKeyCache{keys: make(map[string][]byte)}
+	_, err = keyCache.get(ctx, client, download, otherUrl+"/other/key")
+	if err != nil {
+		t.Fatalf("cannot get key : %s", err)
+	}
+	segment := &hls.Segment{Index: 0, Url: otherUrl + "/other/segment.ts"}
+	err = client.downloadHlsSegment(ctx, download, segment, t.TempDir(), keyCache)
+	if err != nil {
+		t.Fatalf("cannot download segment : %s", err)
+	}
+	if mediaUrl != otherUrl+"/other/variant.m3u8" {
+		t.Errorf("expected media url of the variant, got %s", mediaUrl)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if credentials["/master.m3u8"] == "" {
+		t.Errorf("credentials are not sent to the host of the download")
+	}
+	for _, path := range []string{"/other/variant.m3u8", "/other/key", "/other/segment.ts"} {
+		sent, ok := credentials[path]
+		if !ok {
+			t.Errorf("%s is not requested", path)
+		}
+		if sent != "" {
+			t.Errorf("credentials are sent to the other host for %s : %s", path, sent)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package direct
+
+import (
+	"context"
+	"downite/types"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// hostLimits keeps the connections to every host under its limit across all downloads
+// and applies the overrides of hosts like their speed limits and delays between requests
+type hostLimits struct {
+	settings types.HostSettings
+	// open connections by host key
+	connections map[string]int
+	// next request to the host key is not sent before this time
+	nextRequestAt map[string]time.Time
+	// speed limiters of the overridden hosts. they are updated in place so that running parts pick up new limits
+	speedLimiters map[string]*rate.Limiter
+	// closed and replaced whenever a connection is released so that waiting parts check again
+	released chan struct{}
+	mutex    sync.Mutex
+}
+
+func newHostLimits(settings types.HostSettings) *hostLimits {
+	limits := &hostLimits{
+		connections:   make(map[string]int),
+		nextRequestAt: make(map[string]time.Time),
+		speedLimiters: make(map[string]*rate.Limiter),
+		released:      make(chan struct{}),
+	}
+	limits.setSettings(settings)
+	return limits
+}
+
+// NormalizeHost returns the host name of a host or url in lower case without the port
+func NormalizeHost(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if strings.Contains(host, "://") {
+		parsedUrl, err := url.Parse(host)
+		if err == nil {
+			return parsedUrl.Hostname()
+		}
+	}
+	if parsedUrl, err := url.Parse("//" + host); err == nil {
+		return parsedUrl.Hostname()
+	}
+	return host
+}
+
+func (limits *hostLimits) setSettings(settings types.HostSettings) {
+	limits.mutex.Lock()
+	defer limits.mutex.Unlock()
+
+	overrides := make([]types.HostOverride, len(settings.Overrides))
+	for i, override := range settings.Overrides {
+		override.Host = NormalizeHost(override.Host)
+		overrides[i] = override
+	}
+	settings.Overrides = overrides
+	limits.settings = settings
+
+	// limiters of removed overrides are kept unlimited because running parts may still use them
+	for _, limiter := range limits.speedLimiters {
+		setSpeedLimit(limiter, 0)
+	}
+	for _, override := range overrides {
+		limiter, ok := limits.speedLimiters[override.Host]
+		if !ok {
+			limiter = newSpeedLimiter(0)
+			limits.speedLimiters[override.Host] = limiter
+		}
+		setSpeedLimit(limiter, override.SpeedLimit)
+	}
+	// waiting parts may fit in the new limits
+	close(limits.released)
+	limits.released = make(chan struct{})
+}
+
+func (limits *hostLimits) getSettings() types.HostSettings {
+	limits.mutex.Lock()
+	defer limits.mutex.Unlock()
+
+	settings := limits.settings
+	settings.Overrides = make([]types.HostOverride, len(limits.settings.Overrides))
+	copy(settings.Overrides, limits.settings.Overrides)
+	return settings
+}
+
+// override returns the override of the host. overrides of example.com apply to cdn.example.com too.
+// the most specific override wins. mutex must be held
+func (limits *hostLimits) override(host string) *types.HostOverride {
+	var found *types.HostOverride
+	for i, override := range limits.settings.Overrides {
+		if host != override.Host && !strings.HasSuffix(host, "."+override.Host) {
+			continue
+		}
+		if found == nil || len(override.Host) > len(found.Host) {
+			found = &limits.settings.Overrides[i]
+		}
+	}
+	return found
+}
+
+// hostKey returns the key connections of the host are counted by. subdomains of an overridden host share its connections.
+// mutex must be held
+func (limits *hostLimits) hostKey(host string) (string, *types.HostOverride) {
+	override := limits.override(host)
+	if override != nil {
+		return override.Host, override
+	}
+	return host, nil
+}
+
+// maxConnections returns the connection limit of the host. 0 means unlimited. mutex must be held
+func (limits *hostLimits) maxConnections(override *types.HostOverride) int {
+	if override != nil && override.MaxConnections > 0 {
+		return override.MaxConnections
+	}
+	return limits.settings.MaxConnectionsPerHost
+}
+
+// acquire waits until a connection to the host of the url is free and the delay since the last request
+// to the host has passed. release must be called when the connection is closed
+func (limits *hostLimits) acquire(ctx context.Context, rawUrl string) (func(), error) {
+	host := NormalizeHost(rawUrl)
+	limits.mutex.Lock()
+	for {
+		key, override := limits.hostKey(host)
+		maxConnections := limits.maxConnections(override)
+		if maxConnections == 0 || limits.connections[key] < maxConnections {
+			break
+		}
+		released := limits.released
+		limits.mutex.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-released:
+		}
+		limits.mutex.Lock()
+	}
+
+	key, override := limits.hostKey(host)
+	limits.connections[key]++
+	var wait time.Duration
+	if override != nil && override.RequestDelay > 0 {
+		now := time.Now()
+		requestAt := now
+		if nextRequestAt := limits.nextRequestAt[key]; nextRequestAt.After(now) {
+			requestAt = nextRequestAt
+		}
+		// the time is reserved so that parts waiting at the same time are spaced too
+		limits.nextRequestAt[key] = requestAt.Add(time.Duration(override.RequestDelay) * time.Millisecond)
+		wait = requestAt.Sub(now)
+	}
+	limits.mutex.Unlock()
+
+	release := func() {
+		limits.release(key)
+	}
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			release()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return release, nil
+}
+
+func (limits *hostLimits) release(key string) {
+	limits.mutex.Lock()
+	defer limits.mutex.Unlock()
+
+	limits.connections[key]--
+	if limits.connections[key] <= 0 {
+		delete(limits.connections, key)
+		if limits.nextRequestAt[key].Before(time.Now()) {
+			delete(limits.nextRequestAt, key)
+		}
+	}
+	close(limits.released)
+	limits.released = make(chan struct{})
+}
+
+// speedLimiter returns the speed limiter of the host of the url. it is nil when the host is not overridden
+func (limits *hostLimits) speedLimiter(rawUrl string) *rate.Limiter {
+	limits.mutex.Lock()
+	defer limits.mutex.Unlock()
+
+	override := limits.override(NormalizeHost(rawUrl))
+	if override == nil {
+		return nil
+	}
+	return limits.speedLimiters[override.Host]
+}
+
+// partCount returns the part count of a new download from the host of the url.
+// parts beyond the connection limit of the host would only wait for each other
+func (limits *hostLimits) partCount(rawUrl string, partCount int) int {
+	limits.mutex.Lock()
+	defer limits.mutex.Unlock()
+
+	override := limits.override(NormalizeHost(rawUrl))
+	if override != nil && override.PartCount > 0 {
+		partCount = override.PartCount
+	}
+	if maxConnections := limits.maxConnections(override); maxConnections > 0 && partCount > maxConnections {
+		partCount = maxConnections
+	}
+	return partCount
+}
+
+// SetHostSettings applies the connection limits and overrides of hosts. running parts keep their connections
+// but new connections wait for the new limits. part counts apply to the downloads added after it
+func (client *DirectDownloadEngine) SetHostSettings(settings types.HostSettings) {
+	client.hostLimits.setSettings(settings)
+	client.DownloadClientConfig.Hosts = client.hostLimits.getSettings()
+}
+
+func (client *DirectDownloadEngine) GetHostSettings() types.HostSettings {
+	return client.hostLimits.getSettings()
+}
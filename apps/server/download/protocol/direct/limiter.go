@@ -0,0 +1,84 @@
+package direct
+
+import (
+	"context"
+	"downite/types"
+	"fmt"
+	"io"
+
+	"golang.org/x/time/rate"
+)
+
+// reads are split into chunks of this size so that a single read never asks
+// the limiter for more tokens than its burst allows
+const limitedReadChunkSize = 32 * 1024
+
+// newSpeedLimiter creates a token bucket limiter for the given speed limit in KB/s.
+// 0 means unlimited
+func newSpeedLimiter(speedLimit uint64) *rate.Limiter {
+	limiter := rate.NewLimiter(rate.Inf, limitedReadChunkSize)
+	setSpeedLimit(limiter, speedLimit)
+	return limiter
+}
+
+// setSpeedLimit updates the limiter in place. readers that are already using
+// the limiter pick up the new limit on their next read
+func setSpeedLimit(limiter *rate.Limiter, speedLimit uint64) {
+	if speedLimit == 0 {
+		limiter.SetLimit(rate.Inf)
+		return
+	}
+	bytesPerSecond := int(speedLimit * 1024)
+	burst := bytesPerSecond
+	if burst < limitedReadChunkSize {
+		burst = limitedReadChunkSize
+	}
+	limiter.SetBurst(burst)
+	limiter.SetLimit(rate.Limit(bytesPerSecond))
+}
+
+// getLimiters returns the global limiter, the limiter of the download and the limiter of the host of the url when it has one
+func (client *DirectDownloadEngine) getLimiters(download *types.Download, rawUrl string) ([]*rate.Limiter, error) {
+	client.mutexForLimiters.Lock()
+	downloadLimiter, ok := client.downloadLimiters[download.Id]
+	client.mutexForLimiters.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("speed limiter not found for download")
+	}
+
+	limiters := []*rate.Limiter{client.globalLimiter, downloadLimiter}
+	if hostLimiter := client.hostLimits.speedLimiter(rawUrl); hostLimiter != nil {
+		limiters = append(limiters, hostLimiter)
+	}
+	return limiters, nil
+}
+
+type limitedReader struct {
+	ctx      context.Context
+	reader   io.Reader
+	limiters []*rate.Limiter
+}
+
+func newLimitedReader(ctx context.Context, reader io.Reader, limiters ...*rate.Limiter) *limitedReader {
+	return &limitedReader{
+		ctx:      ctx,
+		reader:   reader,
+		limiters: limiters,
+	}
+}
+
+func (limitedReader *limitedReader) Read(buffer []byte) (int, error) {
+	if len(buffer) > limitedReadChunkSize {
+		buffer = buffer[:limitedReadChunkSize]
+	}
+	n, err := limitedReader.reader.Read(buffer)
+	if n <= 0 {
+		return n, err
+	}
+	for _, limiter := range limitedReader.limiters {
+		if waitErr := limiter.WaitN(limitedReader.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
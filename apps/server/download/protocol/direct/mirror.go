@@ -0,0 +1,143 @@
+package direct
+
+import (
+	"context"
+	"downite/download/protocol/ftp"
+	"downite/types"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// speed of a part is compared with the other mirrors once in this interval
+const slowMirrorCheckInterval = 10 * time.Second
+
+// a part moves to another mirror when the parts on the fastest other mirror are this many times faster
+const slowMirrorRatio = 4
+
+var errMirrorTooSlow = errors.New("mirror is too slow")
+
+// newDownloadMirrors creates the mirrors of a download. first url is the main url of the download.
+// credentials of ftp urls are kept apart from their urls
+func newDownloadMirrors(downloadId int, urls []string) []*types.DownloadMirror {
+	mirrors := make([]*types.DownloadMirror, 0, len(urls))
+	for _, rawUrl := range urls {
+		rawUrl, credentials := ftp.SplitCredentials(rawUrl)
+		mirrors = append(mirrors, &types.DownloadMirror{
+			CreatedAt:   time.Now(),
+			DownloadId:  downloadId,
+			Url:         rawUrl,
+			Credentials: credentials,
+		})
+	}
+	return mirrors
+}
+
+// validateMirrors checks that every mirror serves the same file as the main url. credentials of the headers are only sent to the host of the main url
+func (client *DirectDownloadEngine) validateMirrors(metaInfo *types.DownloadMeta, mirrorUrls []string, headers types.DownloadHeaders, proxyUrl string) ([]string, error) {
+	urls := []string{metaInfo.Url}
+	for _, mirrorUrl := range mirrorUrls {
+		isDuplicate := false
+		for _, url := range urls {
+			if url == mirrorUrl {
+				isDuplicate = true
+				break
+			}
+		}
+		if isDuplicate {
+			continue
+		}
+
+		mirrorMeta, err := client.GetDownloadMeta(mirrorUrl, headers.ForUrl(metaInfo.Url, mirrorUrl), proxyUrl, "")
+		if err != nil {
+			return nil, fmt.Errorf("while getting meta of mirror %s : %s", mirrorUrl, err)
+		}
+		if mirrorMeta.TotalSize != metaInfo.TotalSize {
+			return nil, fmt.Errorf("mirror %s reports size %d but %s reports %d", mirrorUrl, mirrorMeta.TotalSize, metaInfo.Url, metaInfo.TotalSize)
+		}
+		if metaInfo.IsRangeAllowed && !mirrorMeta.IsRangeAllowed {
+			return nil, fmt.Errorf("mirror %s does not support range requests", mirrorUrl)
+		}
+		urls = append(urls, mirrorUrl)
+	}
+	return urls, nil
+}
+
+// loadDownloadMirrors gets the mirrors of the download from db. downloads with a single url have no mirrors in db
+func (client *DirectDownloadEngine) loadDownloadMirrors(download *types.Download) error {
+	mirrors, err := client.db.GetDownloadMirrors(download.Id)
+	if err != nil {
+		return err
+	}
+	if len(mirrors) == 0 {
+		mirrors = newDownloadMirrors(download.Id, []string{download.Url})
+		mirrors[0].Credentials = download.Credentials
+	}
+	// mirrors saved before their credentials were kept apart
+	for _, mirror := range mirrors {
+		if mirror.Credentials == "" {
+			mirror.Url, mirror.Credentials = ftp.SplitCredentials(mirror.Url)
+		}
+	}
+	download.Mirrors = mirrors
+	return nil
+}
+
+// selectMirror picks the enabled mirror with the least running parts. exclude is only picked when it is the last enabled mirror.
+// caller must hold mutexForDownloads
+func selectMirror(download *types.Download, exclude *types.DownloadMirror) *types.DownloadMirror {
+	var selectedMirror *types.DownloadMirror
+	for _, mirror := range download.Mirrors {
+		if mirror.IsDisabled || mirror == exclude {
+			continue
+		}
+		if selectedMirror == nil ||
+			mirror.ActivePartCount < selectedMirror.ActivePartCount ||
+			(mirror.ActivePartCount == selectedMirror.ActivePartCount && mirror.ErrorCount < selectedMirror.ErrorCount) {
+			selectedMirror = mirror
+		}
+	}
+	if selectedMirror == nil && exclude != nil && !exclude.IsDisabled {
+		return exclude
+	}
+	return selectedMirror
+}
+
+// watchMirrorSpeed cancels the attempt with errMirrorTooSlow when the part is much slower than the parts on another mirror
+func (client *DirectDownloadEngine) watchMirrorSpeed(ctx context.Context, cancel context.CancelCauseFunc, download *types.Download, part *types.DownloadPart, mirror *types.DownloadMirror) {
+	ticker := time.NewTicker(slowMirrorCheckInterval)
+	defer ticker.Stop()
+
+	client.mutexForDownloads.Lock()
+	lastDownloadedBytes := part.DownloadedBytes
+	client.mutexForDownloads.Unlock()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		client.mutexForDownloads.Lock()
+		partSpeed := float64(part.DownloadedBytes-lastDownloadedBytes) / slowMirrorCheckInterval.Seconds() / 1024
+		lastDownloadedBytes = part.DownloadedBytes
+
+		var fastestPartSpeed float64
+		for _, otherMirror := range download.Mirrors {
+			if otherMirror == mirror || otherMirror.IsDisabled || otherMirror.ActivePartCount == 0 {
+				continue
+			}
+			otherPartSpeed := float64(otherMirror.DownloadSpeed) / float64(otherMirror.ActivePartCount)
+			if otherPartSpeed > fastestPartSpeed {
+				fastestPartSpeed = otherPartSpeed
+			}
+		}
+		client.mutexForDownloads.Unlock()
+
+		if partSpeed*slowMirrorRatio < fastestPartSpeed {
+			cancel(errMirrorTooSlow)
+			return
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package direct
+
+import (
+	"downite/types"
+	"testing"
+)
+
+func TestSelectMirror(t *testing.T) {
+	download := &types.Download{
+		Mirrors: newDownloadMirrors(1, []string{"http://a/file", "http://b/file", "http://c/file"}),
+	}
+	a, b, c := download.Mirrors[0], download.Mirrors[1], download.Mirrors[2]
+
+	a.ActivePartCount = 2
+	b.ActivePartCount = 1
+	c.ActivePartCount = 1
+	c.ErrorCount = 1
+	if mirror := selectMirror(download, nil); mirror != b {
+		t.Errorf("expected mirror with least parts and errors, got %s", mirror.Url)
+	}
+	if mirror := selectMirror(download, b); mirror != c {
+		t.Errorf("expected excluded mirror to be skipped, got %s", mirror.Url)
+	}
+
+	a.IsDisabled = true
+	c.IsDisabled = true
+	if mirror := selectMirror(download, b); mirror != b {
+		t.Errorf("expected excluded mirror when it is the last enabled one")
+	}
+
+	b.IsDisabled = true
+	if mirror := selectMirror(download, b); mirror != nil {
+		t.Errorf("expected no mirror when all are disabled, got %s", mirror.Url)
+	}
+}
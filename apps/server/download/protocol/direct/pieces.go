@@ -0,0 +1,184 @@
+package direct
+
+import (
+	"context"
+	"downite/types"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+// pieceVerifier checks the pieces of the download file in order while the parts are written.
+// like checksumHasher it only reaches the pieces before the first missing byte
+type pieceVerifier struct {
+	newHash     func() hash.Hash
+	pieceLength int64
+	totalSize   int64
+	hashes      []string
+	// pieces before this one are verified
+	nextPiece int
+	badPieces []int
+}
+
+func newPieceVerifier(download *types.Download) (*pieceVerifier, error) {
+	newHash, ok := checksumHashFuncs[download.PieceHashAlgorithm]
+	if !ok {
+		return nil, fmt.Errorf("unsupported piece hash algorithm : %s", download.PieceHashAlgorithm)
+	}
+	return &pieceVerifier{
+		newHash:     newHash,
+		pieceLength: int64(download.PieceLength),
+		totalSize:   int64(download.TotalSize),
+		hashes:      download.PieceHashes,
+	}, nil
+}
+
+func (verifier *pieceVerifier) pieceRange(index int) (int64, int64) {
+	start := int64(index) * verifier.pieceLength
+	end := start + verifier.pieceLength
+	if end > verifier.totalSize {
+		end = verifier.totalSize
+	}
+	return start, end
+}
+
+// advance verifies the pieces that end before end
+func (verifier *pieceVerifier) advance(path string, end int64) error {
+	var file *os.File
+	defer func() {
+		if file != nil {
+			file.Close()
+		}
+	}()
+
+	for verifier.nextPiece < len(verifier.hashes) {
+		pieceStart, pieceEnd := verifier.pieceRange(verifier.nextPiece)
+		if pieceEnd > end {
+			return nil
+		}
+		if file == nil {
+			var err error
+			file, err = os.Open(path)
+			if err != nil {
+				return err
+			}
+		}
+
+		pieceHash := verifier.newHash()
+		written, err := io.Copy(pieceHash, io.NewSectionReader(file, pieceStart, pieceEnd-pieceStart))
+		if err != nil {
+			return err
+		}
+		if written != pieceEnd-pieceStart {
+			return fmt.Errorf("file ended at byte %d while verifying piece %d", pieceStart+written, verifier.nextPiece)
+		}
+		if !strings.EqualFold(hex.EncodeToString(pieceHash.Sum(nil)), verifier.hashes[verifier.nextPiece]) {
+			verifier.badPieces = append(verifier.badPieces, verifier.nextPiece)
+		}
+		verifier.nextPiece++
+	}
+	return nil
+}
+
+// reset verifies the pieces again starting from the first bad piece
+func (verifier *pieceVerifier) reset() {
+	if len(verifier.badPieces) > 0 {
+		verifier.nextPiece = verifier.badPieces[0]
+	}
+	verifier.badPieces = nil
+}
+
+func (verifier *pieceVerifier) mismatchError() error {
+	if len(verifier.badPieces) == 0 {
+		return nil
+	}
+	return fmt.Errorf("piece hash mismatch : %d of %d pieces are corrupted, first one is piece %d", len(verifier.badPieces), len(verifier.hashes), verifier.badPieces[0])
+}
+
+// SetDownloadPieceHashes sets the hashes every piece of the download is verified against while downloading
+func (client *DirectDownloadEngine) SetDownloadPieceHashes(id int, algorithm string, pieceLength uint64, hashes []string) error {
+	download, err := client.GetDownload(id)
+	if err != nil {
+		return err
+	}
+	algorithm = strings.ToLower(algorithm)
+	if pieceLength == 0 {
+		return fmt.Errorf("piece length must be greater than 0")
+	}
+	for _, pieceHash := range hashes {
+		err := ValidateChecksum(algorithm, pieceHash)
+		if err != nil {
+			return fmt.Errorf("invalid piece hash : %s", err)
+		}
+	}
+
+	client.mutexForDownloads.Lock()
+	defer client.mutexForDownloads.Unlock()
+
+	if download.IsHls {
+		return fmt.Errorf("piece hashes are not supported for hls streams")
+	}
+	if download.IsSizeUnknown {
+		return fmt.Errorf("piece hashes are not supported for files of unknown size")
+	}
+	pieceCount := (download.TotalSize + pieceLength - 1) / pieceLength
+	if uint64(len(hashes)) != pieceCount {
+		return fmt.Errorf("download has %d pieces but %d piece hashes are given", pieceCount, len(hashes))
+	}
+	download.PieceHashAlgorithm = algorithm
+	download.PieceLength = pieceLength
+	download.PieceHashes = make(types.StringList, 0, len(hashes))
+	for _, pieceHash := range hashes {
+		download.PieceHashes = append(download.PieceHashes, strings.ToLower(pieceHash))
+	}
+	return client.db.UpdateDownload(download)
+}
+
+// redownloadBadPieces starts every part overlapping a bad piece again from its beginning. it returns how many completed parts are started again
+func (client *DirectDownloadEngine) redownloadBadPieces(download *types.Download, verifier *pieceVerifier, partProcessChan chan *types.DownloadPart, errorChan chan error) (int, error) {
+	// holding part contexts makes sure download is not paused while the parts are starting
+	client.mutexForPartContexts.Lock()
+	defer client.mutexForPartContexts.Unlock()
+
+	partContexts, ok := client.partContextMap[download.Id]
+	if !ok {
+		return 0, context.Canceled
+	}
+
+	client.mutexForDownloads.Lock()
+	restartedParts := make([]*types.DownloadPart, 0)
+	for _, part := range download.Parts {
+		partStart := int64(part.StartByteIndex)
+		partEnd := partStart + int64(part.PartLength)
+		for _, badPiece := range verifier.badPieces {
+			pieceStart, pieceEnd := verifier.pieceRange(badPiece)
+			if pieceStart < partEnd && pieceEnd > partStart {
+				restartedParts = append(restartedParts, part)
+				break
+			}
+		}
+	}
+	for _, part := range restartedParts {
+		fmt.Printf("downloading part %d of %s again because of a bad piece \n", part.PartIndex, download.Name)
+		download.DownloadedBytes -= part.DownloadedBytes
+		part.DownloadedBytes = 0
+		part.Progress = 0
+		part.Status = types.DownloadStatusDownloading.String()
+		err := client.db.UpdateDownloadPart(part)
+		if err != nil {
+			client.mutexForDownloads.Unlock()
+			return 0, err
+		}
+	}
+	download.UpdateProgress()
+	client.mutexForDownloads.Unlock()
+
+	for _, part := range restartedParts {
+		partContexts = append(partContexts, client.startDownloadPart(download, part, partProcessChan, errorChan))
+	}
+	client.partContextMap[download.Id] = partContexts
+	return len(restartedParts), nil
+}
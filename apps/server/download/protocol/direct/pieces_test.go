@@ -0,0 +1,64 @@
+package direct
+
+import (
+	"crypto/sha1"
+	"downite/types"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPieceVerifierAdvance(t *testing.T) {
+	data := []byte("downite piece verification test data")
+	pieceLength := 8
+	path := filepath.Join(t.TempDir(), "file")
+	err := os.WriteFile(path, data, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hashes := types.StringList{}
+	for start := 0; start < len(data); start += pieceLength {
+		end := min(start+pieceLength, len(data))
+		sum := sha1.Sum(data[start:end])
+		hashes = append(hashes, hex.EncodeToString(sum[:]))
+	}
+	// piece 2 is corrupted
+	hashes[2] = "0000000000000000000000000000000000000000"
+
+	verifier, err := newPieceVerifier(&types.Download{
+		PieceHashAlgorithm: "sha1",
+		PieceLength:        uint64(pieceLength),
+		PieceHashes:        hashes,
+		TotalSize:          uint64(len(data)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only whole pieces before the end are verified
+	err = verifier.advance(path, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verifier.nextPiece != 2 || len(verifier.badPieces) != 0 {
+		t.Errorf("expected 2 verified pieces, got %d and bad pieces %v", verifier.nextPiece, verifier.badPieces)
+	}
+
+	err = verifier.advance(path, int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verifier.nextPiece != len(hashes) || len(verifier.badPieces) != 1 || verifier.badPieces[0] != 2 {
+		t.Errorf("expected piece 2 to be bad, got %v", verifier.badPieces)
+	}
+	if verifier.mismatchError() == nil {
+		t.Errorf("expected mismatch error")
+	}
+
+	verifier.reset()
+	if verifier.nextPiece != 2 || verifier.mismatchError() != nil {
+		t.Errorf("verifier should start again from the bad piece")
+	}
+}
@@ -0,0 +1,109 @@
+package direct
+
+import (
+	"context"
+	"downite/types"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// PlaylistResolver is a resolver that can also expand pages with many files like playlists and channels
+type PlaylistResolver interface {
+	Resolver
+	IsPlaylist(parsedUrl *url.URL) bool
+	// Playlist returns the entries of the page. entry urls are resolved like any other page url
+	Playlist(ctx context.Context, httpClient *http.Client, rawUrl string) (*types.Playlist, error)
+}
+
+// findPlaylistResolver returns the resolver that can expand the url. it returns nil when the url is not a playlist
+func (client *DirectDownloadEngine) findPlaylistResolver(rawUrl string) PlaylistResolver {
+	parsedUrl, err := url.Parse(rawUrl)
+	if err != nil {
+		return nil
+	}
+	playlistResolver, ok := client.Resolvers.Find(rawUrl).(PlaylistResolver)
+	if !ok || !playlistResolver.IsPlaylist(parsedUrl) {
+		return nil
+	}
+	return playlistResolver
+}
+
+// IsPlaylistUrl reports whether the url can be expanded into many downloads
+func (client *DirectDownloadEngine) IsPlaylistUrl(rawUrl string) bool {
+	return client.findPlaylistResolver(rawUrl) != nil
+}
+
+// GetPlaylist lists the entries of a playlist or channel url
+func (client *DirectDownloadEngine) GetPlaylist(rawUrl string, proxyUrl string) (*types.Playlist, error) {
+	playlistResolver := client.findPlaylistResolver(rawUrl)
+	if playlistResolver == nil {
+		return nil, fmt.Errorf("url is not a playlist")
+	}
+	httpClient, err := client.getHttpClient(proxyUrl)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+
+	playlist, err := playlistResolver.Playlist(ctx, httpClient, rawUrl)
+	if err != nil {
+		return nil, fmt.Errorf("while getting playlist with %s : %s", playlistResolver.Name(), err)
+	}
+	playlist.Url = rawUrl
+	playlist.Resolver = playlistResolver.Name()
+	return playlist, nil
+}
+
+// PlaylistEntryName names the entry like "007 - title" so that files of a playlist are sorted in the playlist order
+func PlaylistEntryName(entry types.PlaylistEntry, entryCount int) string {
+	width := max(len(strconv.Itoa(entryCount)), 2)
+	return fmt.Sprintf("%0*d - %s", width, entry.Index, sanitizeFileName(entry.Title))
+}
+
+// PlaylistFolderName names the folder the entries of the playlist are saved in
+func PlaylistFolderName(playlist *types.Playlist) string {
+	if playlist.Title == "" {
+		return "playlist"
+	}
+	return sanitizeFileName(playlist.Title)
+}
+
+func (client *DirectDownloadEngine) CreateDownloadBatch(name string, sourceUrl string) (*types.DownloadBatch, error) {
+	batch := &types.DownloadBatch{
+		CreatedAt: time.Now(),
+		Name:      name,
+		SourceUrl: sourceUrl,
+	}
+	id, err := client.db.InsertDownloadBatch(batch)
+	if err != nil {
+		return nil, err
+	}
+	batch.Id = id
+	return batch, nil
+}
+
+func (client *DirectDownloadEngine) GetDownloadBatches() ([]*types.DownloadBatch, error) {
+	return client.db.GetDownloadBatches()
+}
+
+// SetDownloadBatch adds the download to the batch
+func (client *DirectDownloadEngine) SetDownloadBatch(id int, batchId int) error {
+	download, err := client.GetDownload(id)
+	if err != nil {
+		return err
+	}
+
+	client.mutexForDownloads.Lock()
+	defer client.mutexForDownloads.Unlock()
+
+	download.BatchId = batchId
+	err = client.db.UpdateDownload(download)
+	if err != nil {
+		return err
+	}
+	return nil
+}
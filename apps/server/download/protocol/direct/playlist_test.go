@@ -0,0 +1,62 @@
+package direct
+
+import (
+	"downite/types"
+	"net/url"
+	"testing"
+)
+
+func TestYoutubeIsPlaylist(t *testing.T) {
+	resolver := &youtubeResolver{}
+	testCases := []struct {
+		rawUrl     string
+		isPlaylist bool
+	}{
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ", false},
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ&list=PLx0sYbCqOb8TBPRdmBHs5Iftvv9TPboYG", true},
+		{"https://www.youtube.com/playlist?list=PLx0sYbCqOb8TBPRdmBHs5Iftvv9TPboYG", true},
+		{"https://www.youtube.com/channel/UCuAXFkgsw1L7xaCfnd5JJOw", true},
+		{"https://www.youtube.com/channel/about", false},
+		{"https://www.youtube.com/@handle", true},
+		{"https://www.youtube.com/@handle/videos", true},
+		{"https://www.youtube.com/c/name", true},
+		{"https://www.youtube.com/user/name", true},
+		{"https://youtu.be/dQw4w9WgXcQ", false},
+	}
+	for _, testCase := range testCases {
+		parsedUrl, err := url.Parse(testCase.rawUrl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resolver.IsPlaylist(parsedUrl) != testCase.isPlaylist {
+			t.Errorf("%s : expected playlist to be %t", testCase.rawUrl, testCase.isPlaylist)
+		}
+	}
+}
+
+func TestYoutubeChannelId(t *testing.T) {
+	body := []byte(`{"channelId":"UCaaaaaaaaaaaaaaaaaaaaaa","metadata":{"externalId":"UCuAXFkgsw1L7xaCfnd5JJOw"}}`)
+	channelId, err := youtubeChannelIdFromPage(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if channelId != "UCuAXFkgsw1L7xaCfnd5JJOw" {
+		t.Errorf("expected external id of the page, got %s", channelId)
+	}
+	if _, err := youtubeChannelIdFromPage([]byte("<html></html>")); err == nil {
+		t.Errorf("expected error for pages without channel id")
+	}
+	if playlistId := youtubeUploadsPlaylistId(channelId); playlistId != "UUuAXFkgsw1L7xaCfnd5JJOw" {
+		t.Errorf("unexpected uploads playlist %s", playlistId)
+	}
+}
+
+func TestPlaylistEntryName(t *testing.T) {
+	entry := types.PlaylistEntry{Index: 7, Title: "a/b: c?"}
+	if name := PlaylistEntryName(entry, 5); name != "07 - a_b_ c_" {
+		t.Errorf("unexpected name %s", name)
+	}
+	if name := PlaylistEntryName(entry, 120); name != "007 - a_b_ c_" {
+		t.Errorf("unexpected name %s", name)
+	}
+}
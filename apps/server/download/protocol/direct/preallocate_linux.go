@@ -0,0 +1,16 @@
+//go:build linux
+
+package direct
+
+import (
+	"os"
+	"syscall"
+)
+
+// preallocateFile reserves disk space for the whole file
+func preallocateFile(file *os.File, size int64) error {
+	// not every file system supports fallocate. truncate below still gives the file its size
+	_ = syscall.Fallocate(int(file.Fd()), 0, 0, size)
+	// fallocate only grows the file. truncate makes sure it has the exact size
+	return file.Truncate(size)
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package direct
+
+import "os"
+
+// preallocateFile extends the file to its final size
+func preallocateFile(file *os.File, size int64) error {
+	return file.Truncate(size)
+}
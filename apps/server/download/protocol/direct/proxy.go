@@ -0,0 +1,70 @@
+package direct
+
+import (
+	"downite/download/proxy"
+	"net/http"
+	"net/url"
+)
+
+// downloads with this proxy don't use the proxy in settings
+const noProxy = "direct"
+
+// SetProxy changes the proxy used by downloads without a proxy of their own. running requests keep their connection
+func (client *DirectDownloadEngine) SetProxy(rawProxyUrl string) error {
+	proxyUrl, err := proxy.ParseUrl(rawProxyUrl)
+	if err != nil {
+		return err
+	}
+
+	client.mutexForHttpClients.Lock()
+	defer client.mutexForHttpClients.Unlock()
+
+	client.httpClient = proxy.NewHttpClient(proxyUrl)
+	client.DownloadClientConfig.Proxy = rawProxyUrl
+	return nil
+}
+
+// GetHttpClient returns the http client for a proxy url. empty proxy uses the proxy in settings
+func (client *DirectDownloadEngine) GetHttpClient(rawProxyUrl string) (*http.Client, error) {
+	return client.getHttpClient(rawProxyUrl)
+}
+
+// getHttpClient returns the http client for the proxy of a download. empty proxy uses the proxy in settings
+func (client *DirectDownloadEngine) getHttpClient(rawProxyUrl string) (*http.Client, error) {
+	client.mutexForHttpClients.Lock()
+	defer client.mutexForHttpClients.Unlock()
+
+	if rawProxyUrl == "" {
+		return client.httpClient, nil
+	}
+	httpClient, ok := client.proxyHttpClients[rawProxyUrl]
+	if ok {
+		return httpClient, nil
+	}
+
+	if rawProxyUrl == noProxy {
+		httpClient = proxy.NewDirectHttpClient()
+	} else {
+		proxyUrl, err := proxy.ParseUrl(rawProxyUrl)
+		if err != nil {
+			return nil, err
+		}
+		httpClient = proxy.NewHttpClient(proxyUrl)
+	}
+	client.proxyHttpClients[rawProxyUrl] = httpClient
+	return httpClient, nil
+}
+
+// getProxyUrl returns the proxy of a download for protocols other than http. empty proxy uses the proxy in settings.
+// nil means the connection is opened directly
+func (client *DirectDownloadEngine) getProxyUrl(rawProxyUrl string) (*url.URL, error) {
+	if rawProxyUrl == noProxy {
+		return nil, nil
+	}
+	if rawProxyUrl == "" {
+		client.mutexForHttpClients.Lock()
+		rawProxyUrl = client.DownloadClientConfig.Proxy
+		client.mutexForHttpClients.Unlock()
+	}
+	return proxy.ParseUrl(rawProxyUrl)
+}
@@ -0,0 +1,244 @@
+package direct
+
+import (
+	"context"
+	"downite/types"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// QueueDownload puts the download into the queue. it starts as soon as there is a free slot
+func (client *DirectDownloadEngine) QueueDownload(id int) error {
+	err := client.updateDownloadStatus(id, types.DownloadStatusQueued)
+	if err != nil {
+		return err
+	}
+
+	client.processQueue()
+	return nil
+}
+
+// QueueDownloads puts the downloads into the queue without waiting for the queue to start them
+// because starting a download can take long like resolving its page
+func (client *DirectDownloadEngine) QueueDownloads(ids []int) error {
+	for _, id := range ids {
+		err := client.updateDownloadStatus(id, types.DownloadStatusQueued)
+		if err != nil {
+			return err
+		}
+	}
+
+	go client.processQueue()
+	return nil
+}
+
+// ForceStartDownload starts the download right away without waiting for a free slot in the queue
+func (client *DirectDownloadEngine) ForceStartDownload(id int) error {
+	if client.CheckDownloadStatus(id, types.DownloadStatusDownloading) {
+		return fmt.Errorf("download is already running")
+	}
+	if client.CheckDownloadStatus(id, types.DownloadStatusCompleted) {
+		return fmt.Errorf("download is already completed")
+	}
+	download, err := client.GetDownload(id)
+	if err != nil {
+		return err
+	}
+
+	client.mutexForDownloads.Lock()
+	isMultiPart := download.IsMultiPart
+	client.mutexForDownloads.Unlock()
+	if !isMultiPart {
+		err = client.ReinitilizeDownload(id)
+		if err != nil {
+			return err
+		}
+	}
+
+	// queue must not start the download at the same time
+	client.mutexForQueue.Lock()
+	if _, ok := client.startingDownloads[id]; ok {
+		client.mutexForQueue.Unlock()
+		return fmt.Errorf("download is already starting")
+	}
+	client.startingDownloads[id] = true
+	client.mutexForQueue.Unlock()
+
+	// resolving the page of the download can take long so the queue is not locked meanwhile
+	err = client.StartDownload(id)
+
+	client.mutexForQueue.Lock()
+	defer client.mutexForQueue.Unlock()
+	delete(client.startingDownloads, id)
+	if err != nil {
+		return err
+	}
+	client.forceStartedDownloads[id] = true
+	return nil
+}
+
+// SetQueueActive starts or stops the queue. stopped queue puts its running downloads back to queue and starts nothing until it is active again
+func (client *DirectDownloadEngine) SetQueueActive(active bool) {
+	client.mutexForQueue.Lock()
+	client.isQueueStopped = !active
+	forceStartedDownloads := make(map[int]bool, len(client.forceStartedDownloads))
+	for id := range client.forceStartedDownloads {
+		forceStartedDownloads[id] = true
+	}
+	client.mutexForQueue.Unlock()
+
+	if active {
+		client.processQueue()
+		return
+	}
+
+	runningDownloads := make([]*types.Download, 0)
+	client.mutexForDownloads.Lock()
+	for _, download := range client.downloads {
+		if download.Status == types.DownloadStatusDownloading.String() && !forceStartedDownloads[download.Id] {
+			runningDownloads = append(runningDownloads, download)
+		}
+	}
+	client.mutexForDownloads.Unlock()
+
+	for _, download := range runningDownloads {
+		fmt.Printf("Putting download back to queue : %s \n", download.Name)
+		err := client.stopDownload(download.Id, types.DownloadStatusQueued)
+		if err != nil {
+			fmt.Printf("Error while putting download back to queue : %s \n", err)
+		}
+	}
+}
+
+func (client *DirectDownloadEngine) IsQueueActive() bool {
+	client.mutexForQueue.Lock()
+	defer client.mutexForQueue.Unlock()
+
+	return !client.isQueueStopped
+}
+
+func (client *DirectDownloadEngine) SetMaxConcurrentDownloads(maxConcurrentDownloads int) {
+	client.mutexForQueue.Lock()
+	client.DownloadClientConfig.MaxConcurrentDownloads = maxConcurrentDownloads
+	client.mutexForQueue.Unlock()
+
+	client.processQueue()
+}
+
+func (client *DirectDownloadEngine) GetMaxConcurrentDownloads() int {
+	client.mutexForQueue.Lock()
+	defer client.mutexForQueue.Unlock()
+
+	return client.DownloadClientConfig.MaxConcurrentDownloads
+}
+
+// processQueue starts queued downloads in order of their queue numbers until the max concurrent downloads limit is reached.
+// if more downloads are running than the limit allows, the ones at the end of the queue are put back to the queue
+func (client *DirectDownloadEngine) processQueue() {
+	client.mutexForQueue.Lock()
+
+	maxConcurrentDownloads := client.DownloadClientConfig.MaxConcurrentDownloads
+
+	runningDownloads := make([]*types.Download, 0)
+	queuedDownloads := make([]*types.Download, 0)
+
+	client.mutexForDownloads.Lock()
+	for id := range client.forceStartedDownloads {
+		download, ok := client.downloads[id]
+		if !ok || download.Status != types.DownloadStatusDownloading.String() {
+			delete(client.forceStartedDownloads, id)
+		}
+	}
+	for _, download := range client.downloads {
+		// starting downloads are counted once below
+		if _, ok := client.startingDownloads[download.Id]; ok {
+			continue
+		}
+		switch download.Status {
+		case types.DownloadStatusDownloading.String():
+			if client.forceStartedDownloads[download.Id] {
+				continue
+			}
+			runningDownloads = append(runningDownloads, download)
+		case types.DownloadStatusQueued.String():
+			queuedDownloads = append(queuedDownloads, download)
+		}
+	}
+	sort.Slice(runningDownloads, func(i, j int) bool {
+		return runningDownloads[i].QueueNumber < runningDownloads[j].QueueNumber
+	})
+	sort.Slice(queuedDownloads, func(i, j int) bool {
+		return queuedDownloads[i].QueueNumber < queuedDownloads[j].QueueNumber
+	})
+	client.mutexForDownloads.Unlock()
+
+	if client.isQueueStopped {
+		client.mutexForQueue.Unlock()
+		return
+	}
+	if maxConcurrentDownloads > 0 && len(runningDownloads) > maxConcurrentDownloads {
+		for _, download := range runningDownloads[maxConcurrentDownloads:] {
+			fmt.Printf("Putting download back to queue : %s \n", download.Name)
+			err := client.stopDownload(download.Id, types.DownloadStatusQueued)
+			if err != nil {
+				fmt.Printf("Error while putting download back to queue : %s \n", err)
+			}
+		}
+		client.mutexForQueue.Unlock()
+		return
+	}
+
+	runningCount := len(runningDownloads)
+	for _, isForceStarted := range client.startingDownloads {
+		if !isForceStarted {
+			runningCount++
+		}
+	}
+	startingDownloads := make([]*types.Download, 0)
+	for _, download := range queuedDownloads {
+		if maxConcurrentDownloads > 0 && runningCount >= maxConcurrentDownloads {
+			break
+		}
+		// slot is taken before starting so that other calls don't start more downloads
+		client.startingDownloads[download.Id] = false
+		startingDownloads = append(startingDownloads, download)
+		runningCount++
+	}
+	client.mutexForQueue.Unlock()
+
+	// starting can take long like resolving the page of the download so the queue is only locked to take the slots
+	for _, download := range startingDownloads {
+		client.startQueuedDownload(download)
+	}
+}
+
+// startQueuedDownload starts the download that took a slot of the queue and gives the slot back when it cannot start
+func (client *DirectDownloadEngine) startQueuedDownload(download *types.Download) {
+	err := client.StartDownload(download.Id)
+
+	client.mutexForQueue.Lock()
+	delete(client.startingDownloads, download.Id)
+	isQueueStopped := client.isQueueStopped
+	client.mutexForQueue.Unlock()
+
+	// download is paused while it was starting
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	if err != nil {
+		fmt.Printf("Error while starting queued download : %s \n", err)
+		err = client.failDownload(download.Id, err)
+		if err != nil {
+			fmt.Printf("Error while updating download status in db : %s \n", err)
+		}
+		return
+	}
+	// queue is stopped while the download was starting
+	if isQueueStopped {
+		err = client.stopDownload(download.Id, types.DownloadStatusQueued)
+		if err != nil {
+			fmt.Printf("Error while putting download back to queue : %s \n", err)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package direct
+
+import (
+	"context"
+	"downite/types"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// resolvers give up after this duration
+const resolveTimeout = 30 * time.Second
+
+// Resolver turns a page url like a video page into the urls of the files on it
+type Resolver interface {
+	Name() string
+	CanResolve(parsedUrl *url.URL) bool
+	// Resolve returns the files of the page. formatId is one of the ids listed by Formats. the best format is chosen when it is empty
+	Resolve(ctx context.Context, httpClient *http.Client, rawUrl string, formatId string) ([]types.ResolvedFile, error)
+	// Formats lists the qualities the page can be downloaded in
+	Formats(ctx context.Context, httpClient *http.Client, rawUrl string) ([]types.ResolvedFormat, error)
+}
+
+// ResolverRegistry finds the resolver of a url
+type ResolverRegistry struct {
+	resolvers         []Resolver
+	mutexForResolvers sync.Mutex
+}
+
+func NewResolverRegistry(resolvers ...Resolver) *ResolverRegistry {
+	return &ResolverRegistry{resolvers: resolvers}
+}
+
+// Register adds the resolver. resolvers registered before are tried first
+func (registry *ResolverRegistry) Register(resolver Resolver) {
+	registry.mutexForResolvers.Lock()
+	defer registry.mutexForResolvers.Unlock()
+	registry.resolvers = append(registry.resolvers, resolver)
+}
+
+// Find returns the resolver of the url. it returns nil when the url is a file url
+func (registry *ResolverRegistry) Find(rawUrl string) Resolver {
+	parsedUrl, err := url.Parse(rawUrl)
+	if err != nil {
+		return nil
+	}
+	registry.mutexForResolvers.Lock()
+	defer registry.mutexForResolvers.Unlock()
+	for _, resolver := range registry.resolvers {
+		if resolver.CanResolve(parsedUrl) {
+			return resolver
+		}
+	}
+	return nil
+}
+
+// resolvedUrl is the file of a page url
+type resolvedUrl struct {
+	file     types.ResolvedFile
+	resolver string
+	// headers of the download that may be sent to the file with the headers of the file
+	headers types.DownloadHeaders
+}
+
+// resolveUrl returns the file of the url found by its resolver. it returns nil when no resolver handles the url.
+// pages with more than one file are rejected
+func (client *DirectDownloadEngine) resolveUrl(rawUrl string, headers types.DownloadHeaders, proxyUrl string, formatId string) (*resolvedUrl, error) {
+	resolver := client.Resolvers.Find(rawUrl)
+	if resolver == nil {
+		return nil, nil
+	}
+	httpClient, err := client.getHttpClient(proxyUrl)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+
+	files, err := resolver.Resolve(ctx, httpClient, rawUrl, formatId)
+	if err != nil {
+		return nil, fmt.Errorf("while resolving url with %s : %s", resolver.Name(), err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s found no files on the page", resolver.Name())
+	}
+	// a download has one file. picking one of them silently could download the wrong file
+	if len(files) > 1 {
+		return nil, fmt.Errorf("%s found %d files on the page, add their urls one by one", resolver.Name(), len(files))
+	}
+
+	resolved := &resolvedUrl{
+		file:     files[0],
+		resolver: resolver.Name(),
+		headers:  types.DownloadHeaders{},
+	}
+	// credentials of the page are not sent to file hosts like cdns
+	for key, value := range headers.ForUrl(rawUrl, files[0].Url) {
+		resolved.headers[key] = value
+	}
+	for key, value := range files[0].Headers {
+		resolved.headers[key] = value
+	}
+	return resolved, nil
+}
+
+// refreshResolvedUrl resolves the page of the download again because resolved urls like video urls expire.
+// the page must still have a file of the same size so that the downloaded bytes stay valid
+func (client *DirectDownloadEngine) refreshResolvedUrl(download *types.Download) error {
+	client.mutexForDownloads.Lock()
+	pageUrl := download.PageUrl
+	pageHeaders := download.PageHeaders
+	proxyUrl := download.Proxy
+	formatId := download.FormatId
+	totalSize := download.TotalSize
+	isSizeUnknown := download.IsSizeUnknown
+	isHls := download.IsHls
+	client.mutexForDownloads.Unlock()
+
+	if pageUrl == "" {
+		return nil
+	}
+	resolved, err := client.resolveUrl(pageUrl, pageHeaders, proxyUrl, formatId)
+	if err != nil {
+		return err
+	}
+	// no resolver handles the page anymore. the stored url is tried
+	if resolved == nil {
+		return nil
+	}
+	metaInfo, err := client.fetchDownloadMeta(resolved.file.Url, resolved.headers, proxyUrl)
+	if err != nil {
+		return fmt.Errorf("while getting meta of resolved file : %s", err)
+	}
+	if !isHls && !isSizeUnknown && metaInfo.TotalSize != totalSize {
+		return fmt.Errorf("file of the page has changed from %d to %d bytes, restart the download", totalSize, metaInfo.TotalSize)
+	}
+
+	client.mutexForDownloads.Lock()
+	defer client.mutexForDownloads.Unlock()
+	download.Url = resolved.file.Url
+	if isHls {
+		// the variant picked from a master playlist
+		download.Url = metaInfo.Url
+	}
+	download.Headers = resolved.headers
+	// validators of the new url are sent with range requests
+	download.ETag = metaInfo.ETag
+	download.LastModified = metaInfo.LastModified
+	// resolved downloads have no other mirrors
+	for _, mirror := range download.Mirrors {
+		mirror.Url = download.Url
+	}
+	return nil
+}
+
+// resolvePageDownload gets the file of a download added without resolving its page like a playlist entry
+func (client *DirectDownloadEngine) resolvePageDownload(download *types.Download) error {
+	client.mutexForDownloads.Lock()
+	pageUrl := download.PageUrl
+	pageHeaders := download.PageHeaders
+	proxyUrl := download.Proxy
+	formatId := download.FormatId
+	name := download.Name
+	savePath := download.SavePath
+	client.mutexForDownloads.Unlock()
+
+	file, err := client.getRemoteFile(pageUrl, pageHeaders, proxyUrl, formatId)
+	if err != nil {
+		return err
+	}
+	name = remoteFileName(file, name)
+	// nothing is downloaded yet so the file is not ours
+	if _, err := os.Stat(filepath.Join(savePath, name)); err == nil {
+		return fmt.Errorf("file already exists")
+	}
+
+	client.mutexForDownloads.Lock()
+	defer client.mutexForDownloads.Unlock()
+	download.Name = name
+	client.setRemoteFile(download, file, client.DownloadClientConfig.PartCount)
+	for _, mirror := range download.Mirrors {
+		mirror.Url = download.Url
+	}
+	err = client.db.UpdateDownload(download)
+	if err != nil {
+		return err
+	}
+	return client.RegisterDownloadParts(download)
+}
+
+// GetUrlFormats lists the qualities a page url like a video page can be downloaded in
+func (client *DirectDownloadEngine) GetUrlFormats(rawUrl string, proxyUrl string) ([]types.ResolvedFormat, error) {
+	resolver := client.Resolvers.Find(rawUrl)
+	if resolver == nil {
+		return nil, fmt.Errorf("url has no formats to choose from")
+	}
+	httpClient, err := client.getHttpClient(proxyUrl)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+	return resolver.Formats(ctx, httpClient, rawUrl)
+}
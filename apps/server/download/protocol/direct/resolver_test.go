@@ -0,0 +1,157 @@
+package direct
+
+import (
+	"context"
+	"downite/types"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+type fakeResolver struct{}
+
+func (resolver *fakeResolver) Name() string {
+	return "fake"
+}
+
+func (resolver *fakeResolver) CanResolve(parsedUrl *url.URL) bool {
+	return parsedUrl.Host == "videos.example.com"
+}
+
+func (resolver *fakeResolver) Resolve(ctx context.Context, httpClient *http.Client, rawUrl string, formatId string) ([]types.ResolvedFile, error) {
+	files := []types.ResolvedFile{{
+		Url:     "https://cdn.example.com/" + formatId + ".mp4",
+		Name:    "video.mp4",
+		Headers: types.DownloadHeaders{"Referer": rawUrl},
+	}}
+	if strings.HasSuffix(rawUrl, "/gallery") {
+		files = append(files, types.ResolvedFile{Url: "https://cdn.example.com/2.mp4", Name: "video2.mp4"})
+	}
+	return files, nil
+}
+
+func (resolver *fakeResolver) Formats(ctx context.Context, httpClient *http.Client, rawUrl string) ([]types.ResolvedFormat, error) {
+	return []types.ResolvedFormat{{Id: "720"}}, nil
+}
+
+func TestResolveUrl(t *testing.T) {
+	client := &DirectDownloadEngine{
+		httpClient: http.DefaultClient,
+		Resolvers:  NewResolverRegistry(&youtubeResolver{}),
+	}
+	client.Resolvers.Register(&fakeResolver{})
+
+	if client.Resolvers.Find("https://www.youtube.com/watch?v=x") == nil {
+		t.Errorf("expected youtube resolver for youtube urls")
+	}
+	resolved, err := client.resolveUrl("https://files.example.com/file.zip", nil, "", "")
+	if err != nil || resolved != nil {
+		t.Errorf("file urls must not be resolved, got %v %v", resolved, err)
+	}
+
+	resolved, err = client.resolveUrl("https://videos.example.com/watch/1", types.DownloadHeaders{"Cookie": "a=b", "User-Agent": "downite"}, "", "720")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.file.Url != "https://cdn.example.com/720.mp4" || resolved.resolver != "fake" {
+		t.Errorf("unexpected resolved url %s by %s", resolved.file.Url, resolved.resolver)
+	}
+	if resolved.headers["User-Agent"] != "downite" || resolved.headers["Referer"] != "https://videos.example.com/watch/1" {
+		t.Errorf("expected headers of the download and the file, got %v", resolved.headers)
+	}
+	// cookies of the page are not sent to the cdn
+	if _, ok := resolved.headers["Cookie"]; ok {
+		t.Errorf("credentials of the page are sent to the file host, got %v", resolved.headers)
+	}
+
+	_, err = client.resolveUrl("https://videos.example.com/gallery", nil, "", "")
+	if err == nil {
+		t.Errorf("expected error for pages with more than one file")
+	}
+}
+
+// staticResolver resolves every page of videos.example.com to the same file url
+type staticResolver struct {
+	fakeResolver
+	fileUrl string
+}
+
+func (resolver *staticResolver) Resolve(ctx context.Context, httpClient *http.Client, rawUrl string, formatId string) ([]types.ResolvedFile, error) {
+	return []types.ResolvedFile{{Url: resolver.fileUrl, Name: "video.mp4"}}, nil
+}
+
+func TestRefreshResolvedUrl(t *testing.T) {
+	var fileCookie string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fileCookie = r.Header.Get("Cookie")
+		w.Header().Set("Accept-Ranges", "bytes")
+		w.Header().Set("ETag", `"new"`)
+		w.Header().Set("Content-Length", "10")
+	}))
+	defer server.Close()
+
+	client := &DirectDownloadEngine{
+		httpClient: http.DefaultClient,
+		Resolvers:  NewResolverRegistry(&staticResolver{fileUrl: server.URL + "/video.mp4"}),
+	}
+	download := &types.Download{
+		Url:         "https://cdn.example.com/expired.mp4",
+		PageUrl:     "https://videos.example.com/watch/1",
+		PageHeaders: types.DownloadHeaders{"Cookie": "a=b"},
+		TotalSize:   10,
+		ETag:        `"old"`,
+		Mirrors:     newDownloadMirrors(1, []string{"https://cdn.example.com/expired.mp4"}),
+	}
+	err := client.refreshResolvedUrl(download)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if download.Url != server.URL+"/video.mp4" || download.Mirrors[0].Url != download.Url || download.ETag != `"new"` {
+		t.Errorf("expected resolved url and its validators, got %s %s %s", download.Url, download.Mirrors[0].Url, download.ETag)
+	}
+	// page stays the origin of the cookie. the file on another host doesn't get it
+	if fileCookie != "" || download.Headers["Cookie"] != "" || download.PageHeaders["Cookie"] != "a=b" {
+		t.Errorf("expected the cookie only in the headers of the page, file got %q and headers are %v", fileCookie, download.Headers)
+	}
+
+	// downloaded bytes of the old file must not be continued with another file
+	download.TotalSize = 20
+	if err = client.refreshResolvedUrl(download); err == nil {
+		t.Errorf("expected error when the file of the page has another size")
+	}
+}
+
+func TestChooseYoutubeFormat(t *testing.T) {
+	formats := youtube.FormatList{
+		{ItagNo: 18, MimeType: `video/mp4; codecs="avc1.42001E, mp4a.40.2"`, Bitrate: 500, AudioChannels: 2},
+		{ItagNo: 137, MimeType: `video/mp4; codecs="avc1.640028"`, Bitrate: 4000},
+		{ItagNo: 140, MimeType: `audio/mp4; codecs="mp4a.40.2"`, Bitrate: 128, AudioChannels: 2},
+		{ItagNo: 251, MimeType: `audio/webm; codecs="opus"`, Bitrate: 160, AudioChannels: 2},
+	}
+	testCases := []struct {
+		formatId string
+		itag     int
+	}{
+		{"", 18},
+		{"best", 18},
+		{"audio", 251},
+		{"137", 137},
+	}
+	for _, testCase := range testCases {
+		format, err := chooseYoutubeFormat(formats, testCase.formatId)
+		if err != nil || format.ItagNo != testCase.itag {
+			t.Errorf("%q : expected itag %d, got %v %v", testCase.formatId, testCase.itag, format, err)
+		}
+	}
+	if _, err := chooseYoutubeFormat(formats, "22"); err == nil {
+		t.Errorf("expected error for missing format")
+	}
+
+	if mimeExtension(formats[2].MimeType) != ".m4a" || sanitizeFileName(`a/b: "c"?`) != "a_b_ _c__" {
+		t.Errorf("unexpected file name %s%s", sanitizeFileName(`a/b: "c"?`), mimeExtension(formats[2].MimeType))
+	}
+}
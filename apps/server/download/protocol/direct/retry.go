@@ -0,0 +1,281 @@
+package direct
+
+import (
+	"context"
+	"downite/download/protocol/ftp"
+	"downite/types"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"time"
+)
+
+var errPartIncomplete = errors.New("connection closed before the part is completed")
+
+// statusCodeError is returned when the server responds with a status code we can't download from
+type statusCodeError struct {
+	StatusCode int
+	Status     string
+}
+
+func (err *statusCodeError) Error() string {
+	return fmt.Sprintf("unexpected status code while downloading: %s", err.Status)
+}
+
+// isRetryableError reports whether downloading the part again may succeed.
+// timeouts, dropped connections and server side errors are retried. everything else like 404, 416,
+// unknown hosts or refused connections is fatal so that dead mirrors are disabled right away
+func isRetryableError(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var statusErr *statusCodeError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= http.StatusInternalServerError ||
+			statusErr.StatusCode == http.StatusRequestTimeout ||
+			statusErr.StatusCode == http.StatusTooManyRequests
+	}
+
+	// 4xx replies of ftp servers like 421 too many connections
+	if ftp.IsTemporaryError(err) {
+		return true
+	}
+
+	if errors.Is(err, errPartIncomplete) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// downloadFilePartWithRetry downloads the part and retries with exponential backoff on retryable errors.
+// every retry continues from StartByteIndex+DownloadedBytes of the part. when the download has mirrors,
+// the part moves to another mirror if its mirror fails or is too slow
+func (client *DirectDownloadEngine) downloadFilePartWithRetry(download *types.Download, downloadPart *types.DownloadPart, file *os.File, ctx context.Context, isRangeAllowed bool) error {
+	retry := client.retrySettings()
+	retryDelay := retry.delay
+
+	client.mutexForDownloads.Lock()
+	mirror := selectMirror(download, nil)
+	client.mutexForDownloads.Unlock()
+
+	for attempt := 0; ; {
+		if mirror == nil {
+			return fmt.Errorf("no mirror left to download from")
+		}
+
+		// connection counts against the host until the part is downloaded from the mirror.
+		// part is queued while it waits so that it isn't taken for a slow part
+		client.setPartStatus(ctx, downloadPart, types.DownloadStatusQueued)
+		release, err := client.hostLimits.acquire(ctx, mirror.Url)
+		if err != nil {
+			return context.Canceled
+		}
+		client.setPartStatus(ctx, downloadPart, types.DownloadStatusDownloading)
+
+		client.mutexForDownloads.Lock()
+		if !isRangeAllowed {
+			// server can't continue from where we left. start the part from the beginning
+			download.DownloadedBytes -= downloadPart.DownloadedBytes
+			downloadPart.DownloadedBytes = 0
+		}
+		mirror.ActivePartCount++
+		downloadPart.MirrorUrl = mirror.Url
+		hasMirrors := len(download.Mirrors) > 1
+		client.mutexForDownloads.Unlock()
+
+		attemptCtx, cancelAttempt := context.WithCancelCause(ctx)
+		if hasMirrors {
+			go client.watchMirrorSpeed(attemptCtx, cancelAttempt, download, downloadPart, mirror)
+		}
+		err = client.downloadFilePart(download, downloadPart, file, mirror, attemptCtx, isRangeAllowed)
+		if ctx.Err() == nil && errors.Is(context.Cause(attemptCtx), errMirrorTooSlow) {
+			err = errMirrorTooSlow
+		}
+		cancelAttempt(nil)
+		release()
+
+		client.mutexForDownloads.Lock()
+		mirror.ActivePartCount--
+		client.mutexForDownloads.Unlock()
+
+		if err == nil && download.IsSizeUnknown {
+			// server closed the stream. the part ends here
+			client.mutexForDownloads.Lock()
+			downloadPart.PartLength = downloadPart.DownloadedBytes
+			downloadPart.EndByteIndex = downloadPart.StartByteIndex + downloadPart.DownloadedBytes
+			client.mutexForDownloads.Unlock()
+		}
+		if err == nil && downloadPart.DownloadedBytes < downloadPart.PartLength {
+			err = errPartIncomplete
+		}
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return context.Canceled
+		}
+
+		client.mutexForDownloads.Lock()
+		mirror.ErrorCount++
+		mirror.Error = err.Error()
+		previousMirror := mirror
+		switch {
+		case errors.Is(err, errMirrorTooSlow):
+			mirror = selectMirror(download, mirror)
+		case !isRetryableError(err):
+			// this mirror can't serve the file. others may still do
+			mirror.IsDisabled = true
+			mirror = selectMirror(download, nil)
+		default:
+			mirror = selectMirror(download, mirror)
+		}
+		client.mutexForDownloads.Unlock()
+
+		if errors.Is(err, errMirrorTooSlow) {
+			// other mirrors may be disabled meanwhile. the part stays on its mirror then
+			if mirror == nil {
+				mirror = previousMirror
+			}
+			if mirror != previousMirror {
+				fmt.Printf("Moving part %d of %s from %s to %s : %s \n", downloadPart.PartIndex, download.Name, previousMirror.Url, mirror.Url, err)
+			}
+			continue
+		}
+		if !isRetryableError(err) {
+			if mirror == nil {
+				return err
+			}
+			fmt.Printf("Moving part %d of %s from %s to %s : %s \n", downloadPart.PartIndex, download.Name, previousMirror.Url, mirror.Url, err)
+			continue
+		}
+		if attempt >= retry.count {
+			return err
+		}
+		attempt++
+
+		// another mirror can be tried right away
+		if mirror != previousMirror {
+			fmt.Printf("Retrying part %d of %s on %s (%d/%d) : %s \n", downloadPart.PartIndex, download.Name, mirror.Url, attempt, retry.count, err)
+			continue
+		}
+
+		fmt.Printf("Retrying part %d of %s in %s (%d/%d) : %s \n", downloadPart.PartIndex, download.Name, retryDelay, attempt, retry.count, err)
+		select {
+		case <-ctx.Done():
+			return context.Canceled
+		case <-time.After(retryDelay):
+		}
+
+		retryDelay *= 2
+		if retryDelay > retry.maxDelay {
+			retryDelay = retry.maxDelay
+		}
+	}
+}
+
+// retryWithBackoff runs download until it succeeds, fails with a non retryable error or runs out of retries.
+// it is used for downloads that are not split into parts like hls segments
+func (client *DirectDownloadEngine) retryWithBackoff(ctx context.Context, name string, download func() error) error {
+	retry := client.retrySettings()
+	retryDelay := retry.delay
+	for attempt := 0; ; attempt++ {
+		err := download()
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return context.Canceled
+		}
+		if !isRetryableError(err) || attempt >= retry.count {
+			return err
+		}
+
+		fmt.Printf("Retrying %s in %s (%d/%d) : %s \n", name, retryDelay, attempt+1, retry.count, err)
+		select {
+		case <-ctx.Done():
+			return context.Canceled
+		case <-time.After(retryDelay):
+		}
+
+		retryDelay *= 2
+		if retryDelay > retry.maxDelay {
+			retryDelay = retry.maxDelay
+		}
+	}
+}
+
+// setPartStatus changes the status of a running part. status of a stopped part is left to stopDownload
+func (client *DirectDownloadEngine) setPartStatus(ctx context.Context, downloadPart *types.DownloadPart, status types.DownloadStatus) {
+	client.mutexForDownloads.Lock()
+	defer client.mutexForDownloads.Unlock()
+
+	if ctx.Err() != nil {
+		return
+	}
+	downloadPart.Status = status.String()
+}
+
+// retryConfig is copied when a part starts. changed settings apply to the parts started after them
+type retryConfig struct {
+	count    int
+	delay    time.Duration
+	maxDelay time.Duration
+}
+
+func (client *DirectDownloadEngine) retrySettings() retryConfig {
+	client.mutexForRetry.Lock()
+	defer client.mutexForRetry.Unlock()
+
+	return retryConfig{
+		count:    client.DownloadClientConfig.RetryCount,
+		delay:    client.DownloadClientConfig.RetryDelay,
+		maxDelay: client.DownloadClientConfig.MaxRetryDelay,
+	}
+}
+
+// SetRetrySettings changes how failed parts and segments are retried. running parts keep their settings
+func (client *DirectDownloadEngine) SetRetrySettings(settings types.RetrySettings) {
+	client.mutexForRetry.Lock()
+	defer client.mutexForRetry.Unlock()
+
+	client.DownloadClientConfig.RetryCount = settings.RetryCount
+	client.DownloadClientConfig.RetryDelay = time.Duration(settings.RetryDelay) * time.Millisecond
+	client.DownloadClientConfig.MaxRetryDelay = time.Duration(settings.MaxRetryDelay) * time.Millisecond
+}
+
+// SetRestartChangedDownloads chooses whether downloads failed with ErrRemoteFileChanged are restarted
+func (client *DirectDownloadEngine) SetRestartChangedDownloads(restartChangedDownloads bool) {
+	client.mutexForRetry.Lock()
+	defer client.mutexForRetry.Unlock()
+
+	client.DownloadClientConfig.RestartChangedDownloads = restartChangedDownloads
+}
+
+func (client *DirectDownloadEngine) GetRestartChangedDownloads() bool {
+	client.mutexForRetry.Lock()
+	defer client.mutexForRetry.Unlock()
+
+	return client.DownloadClientConfig.RestartChangedDownloads
+}
+
+func (client *DirectDownloadEngine) GetRetrySettings() types.RetrySettings {
+	client.mutexForRetry.Lock()
+	defer client.mutexForRetry.Unlock()
+
+	return types.RetrySettings{
+		RetryCount:    client.DownloadClientConfig.RetryCount,
+		RetryDelay:    int(client.DownloadClientConfig.RetryDelay / time.Millisecond),
+		MaxRetryDelay: int(client.DownloadClientConfig.MaxRetryDelay / time.Millisecond),
+	}
+}
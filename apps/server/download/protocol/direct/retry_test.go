@@ -0,0 +1,97 @@
+package direct
+
+import (
+	"context"
+	"downite/types"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestIsRetryableError(t *testing.T) {
+	testCases := []struct {
+		name      string
+		err       error
+		retryable bool
+	}{
+		{"canceled", context.Canceled, false},
+		{"not found", &statusCodeError{StatusCode: 404, Status: "404 Not Found"}, false},
+		{"range not satisfiable", &statusCodeError{StatusCode: 416, Status: "416 Requested Range Not Satisfiable"}, false},
+		{"service unavailable", &statusCodeError{StatusCode: 503, Status: "503 Service Unavailable"}, true},
+		{"too many requests", &statusCodeError{StatusCode: 429, Status: "429 Too Many Requests"}, true},
+		{"connection reset", fmt.Errorf("while download : %w", &net.OpError{Op: "read", Err: syscall.ECONNRESET}), true},
+		{"timeout", fmt.Errorf("while download : %w", &net.OpError{Op: "read", Err: &net.DNSError{Err: "i/o timeout", IsTimeout: true}}), true},
+		{"no such host", fmt.Errorf("while download : %w", &net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", IsNotFound: true}}), false},
+		{"connection refused", fmt.Errorf("while download : %w", &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}), false},
+		{"unexpected eof", fmt.Errorf("while download : %w", io.ErrUnexpectedEOF), true},
+		{"incomplete part", errPartIncomplete, true},
+		{"unknown", fmt.Errorf("server ignored the range request"), false},
+	}
+	for _, testCase := range testCases {
+		if isRetryableError(testCase.err) != testCase.retryable {
+			t.Errorf("%s : expected retryable to be %t", testCase.name, testCase.retryable)
+		}
+	}
+}
+
+func TestRetrySettings(t *testing.T) {
+	var requestCount atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestCount.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		name     string
+		settings types.RetrySettings
+		// shortest time the retries wait in total
+		minDuration time.Duration
+	}{
+		{"no retries", types.RetrySettings{RetryCount: 0, RetryDelay: 1000, MaxRetryDelay: 1000}, 0},
+		{"delay doubles", types.RetrySettings{RetryCount: 3, RetryDelay: 20, MaxRetryDelay: 1000}, 140 * time.Millisecond},
+		{"delay is capped", types.RetrySettings{RetryCount: 4, RetryDelay: 20, MaxRetryDelay: 30}, 110 * time.Millisecond},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client, err := CreateDownloadClient(&DownloadClientConfig{RetryCount: 5, RetryDelay: time.Hour, MaxRetryDelay: time.Hour}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			client.SetRetrySettings(testCase.settings)
+			if client.GetRetrySettings() != testCase.settings {
+				t.Fatalf("expected settings %+v, got %+v", testCase.settings, client.GetRetrySettings())
+			}
+
+			download := &types.Download{Id: 1, Url: server.URL, Mirrors: newDownloadMirrors(1, []string{server.URL})}
+			part := &types.DownloadPart{PartIndex: 1, PartLength: 10, EndByteIndex: 9}
+			client.downloadLimiters[download.Id] = newSpeedLimiter(0)
+			file, err := os.Create(filepath.Join(t.TempDir(), "file"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+
+			requestCount.Store(0)
+			start := time.Now()
+			err = client.downloadFilePartWithRetry(download, part, file, context.Background(), true)
+			if err == nil {
+				t.Fatalf("expected the part to fail")
+			}
+			if int(requestCount.Load()) != testCase.settings.RetryCount+1 {
+				t.Errorf("expected %d requests, got %d", testCase.settings.RetryCount+1, requestCount.Load())
+			}
+			if time.Since(start) < testCase.minDuration || time.Since(start) > testCase.minDuration+5*time.Second {
+				t.Errorf("expected retries to take about %s, took %s", testCase.minDuration, time.Since(start))
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package direct
+
+import (
+	"context"
+	"downite/types"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// a part is split only when both halves of its remaining range are at least this long.
+// it must be bigger than a single read so that a read in flight never passes the new end of the part
+const minSplitSize = 1024 * 1024
+
+// progressWriter counts the bytes of the part, its mirror and its download. many parts write to the same download at once
+type progressWriter struct {
+	client   *DirectDownloadEngine
+	download *types.Download
+	part     *types.DownloadPart
+	mirror   *types.DownloadMirror
+}
+
+func (progressWriter *progressWriter) Write(bytes []byte) (int, error) {
+	progressWriter.client.mutexForDownloads.Lock()
+	defer progressWriter.client.mutexForDownloads.Unlock()
+
+	progressWriter.part.Write(bytes)
+	progressWriter.mirror.Write(bytes)
+	return progressWriter.download.Write(bytes)
+}
+
+// partReader stops reading when the part reaches its end. end of the part can move while downloading because of splitting
+type partReader struct {
+	client *DirectDownloadEngine
+	part   *types.DownloadPart
+	reader io.Reader
+	// the only part of a file of unknown size is read until the server closes the stream
+	isSizeUnknown bool
+}
+
+func (partReader *partReader) Read(buffer []byte) (int, error) {
+	if partReader.isSizeUnknown {
+		return partReader.reader.Read(buffer)
+	}
+	partReader.client.mutexForDownloads.Lock()
+	var remaining uint64
+	if partReader.part.PartLength > partReader.part.DownloadedBytes {
+		remaining = partReader.part.PartLength - partReader.part.DownloadedBytes
+	}
+	partReader.client.mutexForDownloads.Unlock()
+
+	if remaining == 0 {
+		return 0, io.EOF
+	}
+	if uint64(len(buffer)) > remaining {
+		buffer = buffer[:remaining]
+	}
+	return partReader.reader.Read(buffer)
+}
+
+// startDownloadPart downloads the part in a new goroutine. completed parts are sent to partProcessChan and failed ones to errorChan
+func (client *DirectDownloadEngine) startDownloadPart(download *types.Download, part *types.DownloadPart, partProcessChan chan *types.DownloadPart, errorChan chan error) *contextWithCancel {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// watcher only needs the first error. don't block if it stopped listening
+	reportError := func(err error) {
+		select {
+		case errorChan <- err:
+		default:
+		}
+	}
+	reportCompleted := func() {
+		select {
+		case partProcessChan <- part:
+		case <-ctx.Done():
+		}
+	}
+
+	go func() {
+		// part is already downloaded before. length of the part is not known for files of unknown size
+		if part.DownloadedBytes == part.PartLength && !download.IsSizeUnknown {
+			reportCompleted()
+			return
+		}
+
+		file, err := openDownloadFile(download)
+		if err != nil {
+			part.Status = types.DownloadStatusError.String()
+			part.Error = err.Error()
+			reportError(err)
+			return
+		}
+		defer file.Close()
+
+		err = client.downloadFilePartWithRetry(download, part, file, ctx, download.IsMultiPart)
+		if err != nil {
+			// if download is canceled then return
+			if errors.Is(err, context.Canceled) || ctx.Err() != nil {
+				reportError(context.Canceled)
+				return
+			}
+			part.Status = types.DownloadStatusError.String()
+			part.Error = err.Error()
+			reportError(err)
+			return
+		}
+		if part.DownloadedBytes == part.PartLength {
+			part.Error = ""
+			reportCompleted()
+			return
+		}
+
+		part.Status = types.DownloadStatusError.String()
+		err = fmt.Errorf("downloaded bytes %d is not equal to part length %d", part.DownloadedBytes, part.PartLength)
+		part.Error = err.Error()
+		reportError(err)
+	}()
+
+	return &contextWithCancel{
+		ctx:    &ctx,
+		cancel: cancel,
+	}
+}
+
+// splitSlowestPart finds the running part which would finish last and gives the second half of its remaining range to a new part.
+// new part is saved to db and started right away
+func (client *DirectDownloadEngine) splitSlowestPart(id int, partProcessChan chan *types.DownloadPart, errorChan chan error) error {
+	// holding part contexts makes sure download is not paused while the new part is starting
+	client.mutexForPartContexts.Lock()
+	defer client.mutexForPartContexts.Unlock()
+
+	partContexts, ok := client.partContextMap[id]
+	if !ok {
+		return nil
+	}
+
+	download, err := client.GetDownload(id)
+	if err != nil {
+		return err
+	}
+
+	client.mutexForDownloads.Lock()
+	defer client.mutexForDownloads.Unlock()
+
+	if !download.IsMultiPart {
+		return nil
+	}
+
+	slowestPart, newPart := splitPart(download)
+	if slowestPart == nil {
+		return nil
+	}
+
+	fmt.Printf("splitting part %d of %s. new part %d starts from byte %d \n", slowestPart.PartIndex, download.Name, newPart.PartIndex, newPart.StartByteIndex)
+
+	err = client.db.UpdateDownloadPart(slowestPart)
+	if err != nil {
+		return err
+	}
+	err = client.db.InsertDownloadParts([]*types.DownloadPart{newPart})
+	if err != nil {
+		return err
+	}
+
+	download.Parts = append(download.Parts, newPart)
+	download.PartCount = len(download.Parts)
+	err = client.db.UpdateDownload(download)
+	if err != nil {
+		return err
+	}
+
+	client.partContextMap[id] = append(partContexts, client.startDownloadPart(download, newPart, partProcessChan, errorChan))
+	return nil
+}
+
+// splitPart gives the second half of the remaining range of the running part which would finish last to a new part.
+// it returns nil if no part is long enough to split
+func splitPart(download *types.Download) (*types.DownloadPart, *types.DownloadPart) {
+	var slowestPart *types.DownloadPart
+	var slowestPartTimeLeft float64
+	lastPartIndex := 0
+	for _, part := range download.Parts {
+		if part.PartIndex > lastPartIndex {
+			lastPartIndex = part.PartIndex
+		}
+		if part.Status != types.DownloadStatusDownloading.String() || part.DownloadedBytes >= part.PartLength {
+			continue
+		}
+		remaining := part.PartLength - part.DownloadedBytes
+		if remaining/2 < minSplitSize {
+			continue
+		}
+		timeLeft := math.Inf(1)
+		if part.DownloadSpeed != 0 {
+			timeLeft = float64(remaining) / float64(part.DownloadSpeed*1024)
+		}
+		if slowestPart == nil || timeLeft > slowestPartTimeLeft ||
+			(timeLeft == slowestPartTimeLeft && remaining > slowestPart.PartLength-slowestPart.DownloadedBytes) {
+			slowestPart = part
+			slowestPartTimeLeft = timeLeft
+		}
+	}
+	if slowestPart == nil {
+		return nil, nil
+	}
+
+	remaining := slowestPart.PartLength - slowestPart.DownloadedBytes
+	newPartLength := slowestPart.DownloadedBytes + remaining/2
+	newPart := &types.DownloadPart{
+		CreatedAt:      time.Now(),
+		PartIndex:      lastPartIndex + 1,
+		StartByteIndex: slowestPart.StartByteIndex + newPartLength,
+		EndByteIndex:   slowestPart.EndByteIndex,
+		PartLength:     slowestPart.PartLength - newPartLength,
+		Status:         types.DownloadStatusDownloading.String(),
+		DownloadId:     download.Id,
+	}
+	slowestPart.PartLength = newPartLength
+	slowestPart.EndByteIndex = newPart.StartByteIndex - 1
+	return slowestPart, newPart
+}
@@ -0,0 +1,142 @@
+package direct
+
+import (
+	"downite/types"
+	"sort"
+	"testing"
+)
+
+// checkPartsCoverFile fails if parts leave a gap, overlap or don't cover the whole file
+func checkPartsCoverFile(t *testing.T, download *types.Download) {
+	t.Helper()
+	parts := make([]*types.DownloadPart, len(download.Parts))
+	copy(parts, download.Parts)
+	sort.Slice(parts, func(i, j int) bool {
+		return parts[i].StartByteIndex < parts[j].StartByteIndex
+	})
+
+	var nextStartByteIndex uint64
+	for i, part := range parts {
+		if part.StartByteIndex != nextStartByteIndex {
+			t.Fatalf("part %d starts from byte %d, expected %d", part.PartIndex, part.StartByteIndex, nextStartByteIndex)
+		}
+		if part.DownloadedBytes > part.PartLength {
+			t.Fatalf("part %d has %d downloaded bytes but its length is %d", part.PartIndex, part.DownloadedBytes, part.PartLength)
+		}
+		nextStartByteIndex = part.StartByteIndex + part.PartLength
+		if i < len(parts)-1 && part.EndByteIndex != nextStartByteIndex-1 {
+			t.Fatalf("part %d ends at byte %d, expected %d", part.PartIndex, part.EndByteIndex, nextStartByteIndex-1)
+		}
+	}
+	if nextStartByteIndex != download.TotalSize {
+		t.Fatalf("parts cover %d bytes of %d", nextStartByteIndex, download.TotalSize)
+	}
+}
+
+func TestCreateDownloadParts(t *testing.T) {
+	testCases := []struct {
+		name      string
+		totalSize uint64
+		partCount int
+	}{
+		{"single part", 1000, 1},
+		{"even split", 8 * minSplitSize, 8},
+		{"last part gets the rest", 10*minSplitSize + 7, 8},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			download := &types.Download{
+				TotalSize:  testCase.totalSize,
+				PartCount:  testCase.partCount,
+				PartLength: testCase.totalSize / uint64(testCase.partCount),
+				Parts:      make([]*types.DownloadPart, testCase.partCount),
+			}
+			createDownloadParts(download)
+			checkPartsCoverFile(t, download)
+		})
+	}
+}
+
+func TestSplitPart(t *testing.T) {
+	testCases := []struct {
+		name      string
+		totalSize uint64
+		partCount int
+		// downloaded bytes and speed of every part before splitting
+		downloadedBytes []uint64
+		speeds          []uint64
+		// index of the part expected to be split. -1 means no part is split
+		splitPartIndex int
+	}{
+		{"slowest part is split", 16 * minSplitSize, 4, []uint64{0, minSplitSize, 0, 0}, []uint64{100, 100, 10, 100}, 3},
+		{"stalled part is split first", 16 * minSplitSize, 4, []uint64{0, 0, 0, 0}, []uint64{100, 0, 10, 100}, 2},
+		{"longest remaining range breaks ties", 16*minSplitSize + 5, 4, []uint64{minSplitSize, 0, 2 * minSplitSize, 0}, []uint64{0, 0, 0, 0}, 4},
+		{"short parts are not split", 4*minSplitSize - 4, 4, []uint64{0, 0, 0, 0}, []uint64{0, 0, 0, 0}, -1},
+		{"finished parts are not split", 16 * minSplitSize, 2, []uint64{8 * minSplitSize, 8 * minSplitSize}, []uint64{0, 0}, -1},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			download := &types.Download{
+				TotalSize:  testCase.totalSize,
+				PartCount:  testCase.partCount,
+				PartLength: testCase.totalSize / uint64(testCase.partCount),
+				Parts:      make([]*types.DownloadPart, testCase.partCount),
+			}
+			createDownloadParts(download)
+			for i, part := range download.Parts {
+				part.Status = types.DownloadStatusDownloading.String()
+				part.DownloadedBytes = testCase.downloadedBytes[i]
+				part.DownloadSpeed = testCase.speeds[i]
+			}
+
+			slowestPart, newPart := splitPart(download)
+			if testCase.splitPartIndex == -1 {
+				if slowestPart != nil || newPart != nil {
+					t.Fatalf("expected no split, part %d is split", slowestPart.PartIndex)
+				}
+				return
+			}
+			if slowestPart == nil {
+				t.Fatalf("expected part %d to be split", testCase.splitPartIndex)
+			}
+			if slowestPart.PartIndex != testCase.splitPartIndex {
+				t.Errorf("expected part %d to be split, got part %d", testCase.splitPartIndex, slowestPart.PartIndex)
+			}
+			if newPart.PartIndex != testCase.partCount+1 {
+				t.Errorf("expected new part index %d, got %d", testCase.partCount+1, newPart.PartIndex)
+			}
+			download.Parts = append(download.Parts, newPart)
+			checkPartsCoverFile(t, download)
+		})
+	}
+}
+
+func TestSplitPartRepeatedly(t *testing.T) {
+	download := &types.Download{
+		TotalSize:  64*minSplitSize + 3,
+		PartCount:  3,
+		PartLength: (64*minSplitSize + 3) / 3,
+		Parts:      make([]*types.DownloadPart, 3),
+	}
+	createDownloadParts(download)
+	for i, part := range download.Parts {
+		part.Status = types.DownloadStatusDownloading.String()
+		part.DownloadedBytes = uint64(i) * minSplitSize / 3
+		part.DownloadSpeed = uint64(i + 1)
+	}
+
+	for {
+		slowestPart, newPart := splitPart(download)
+		if slowestPart == nil {
+			break
+		}
+		download.Parts = append(download.Parts, newPart)
+		checkPartsCoverFile(t, download)
+	}
+	// every part is shorter than two splits now
+	for _, part := range download.Parts {
+		if (part.PartLength-part.DownloadedBytes)/2 >= minSplitSize {
+			t.Errorf("part %d with %d remaining bytes is not split", part.PartIndex, part.PartLength-part.DownloadedBytes)
+		}
+	}
+}
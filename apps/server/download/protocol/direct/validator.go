@@ -0,0 +1,65 @@
+package direct
+
+import (
+	"downite/types"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ErrRemoteFileChanged is returned when the file on the server is not the file the download was started with
+var ErrRemoteFileChanged = errors.New("remote file changed since the download started")
+
+// rangeValidator makes sure that the bytes of a range request belong to the same file as the downloaded bytes
+type rangeValidator struct {
+	etag         string
+	lastModified string
+	totalSize    uint64
+}
+
+// newRangeValidator creates the validator of a part request. etag and last modified are only known for the main url of the download
+func newRangeValidator(download *types.Download, rawUrl string) *rangeValidator {
+	validator := &rangeValidator{totalSize: download.TotalSize}
+	if rawUrl == download.Url {
+		validator.etag = download.ETag
+		validator.lastModified = download.LastModified
+	}
+	return validator
+}
+
+// ifRange returns the value of the If-Range header. weak etags can't be used with If-Range so last modified date is used for them
+func (validator *rangeValidator) ifRange() string {
+	if validator.etag != "" && !strings.HasPrefix(validator.etag, "W/") {
+		return validator.etag
+	}
+	return validator.lastModified
+}
+
+// check returns ErrRemoteFileChanged when the response is not a part of the same file
+func (validator *rangeValidator) check(res *http.Response) error {
+	// server sends the whole file instead of the range when If-Range doesn't match
+	if res.StatusCode == http.StatusOK && validator.ifRange() != "" {
+		return ErrRemoteFileChanged
+	}
+	if res.StatusCode != http.StatusPartialContent {
+		return nil
+	}
+
+	// some servers ignore If-Range. compare the validators of the response too
+	etag := res.Header.Get("ETag")
+	if validator.etag != "" && etag != "" && strings.TrimPrefix(etag, "W/") != strings.TrimPrefix(validator.etag, "W/") {
+		return ErrRemoteFileChanged
+	}
+	// EXAMPLE HEADER = "bytes 0-1023/146515"
+	contentRange := res.Header.Get("Content-Range")
+	_, totalSize, found := strings.Cut(contentRange, "/")
+	if !found || totalSize == "*" {
+		return nil
+	}
+	size, err := strconv.ParseUint(totalSize, 10, 64)
+	if err == nil && validator.totalSize != 0 && size != validator.totalSize {
+		return ErrRemoteFileChanged
+	}
+	return nil
+}
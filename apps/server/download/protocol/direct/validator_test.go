@@ -0,0 +1,55 @@
+package direct
+
+import (
+	"downite/types"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestRangeValidator(t *testing.T) {
+	download := &types.Download{
+		Url:          "http://a/file",
+		TotalSize:    1000,
+		ETag:         `"v1"`,
+		LastModified: "Mon, 02 Jan 2006 15:04:05 GMT",
+	}
+	validator := newRangeValidator(download, download.Url)
+	if validator.ifRange() != `"v1"` {
+		t.Errorf("expected strong etag in If-Range, got %s", validator.ifRange())
+	}
+	if mirrorValidator := newRangeValidator(download, "http://b/file"); mirrorValidator.ifRange() != "" {
+		t.Errorf("validators of the main url must not be sent to mirrors")
+	}
+	download.ETag = `W/"v1"`
+	if newRangeValidator(download, download.Url).ifRange() != download.LastModified {
+		t.Errorf("expected last modified in If-Range for weak etags")
+	}
+
+	testCases := []struct {
+		name         string
+		statusCode   int
+		etag         string
+		contentRange string
+		changed      bool
+	}{
+		{"same file", http.StatusPartialContent, `"v1"`, "bytes 0-99/1000", false},
+		{"whole file sent", http.StatusOK, "", "", true},
+		{"etag changed", http.StatusPartialContent, `"v2"`, "bytes 0-99/1000", true},
+		{"size changed", http.StatusPartialContent, "", "bytes 0-99/2000", true},
+		{"unknown size", http.StatusPartialContent, "", "bytes 0-99/*", false},
+	}
+	for _, testCase := range testCases {
+		res := &http.Response{StatusCode: testCase.statusCode, Header: http.Header{}}
+		if testCase.etag != "" {
+			res.Header.Set("ETag", testCase.etag)
+		}
+		if testCase.contentRange != "" {
+			res.Header.Set("Content-Range", testCase.contentRange)
+		}
+		err := validator.check(res)
+		if errors.Is(err, ErrRemoteFileChanged) != testCase.changed {
+			t.Errorf("%s : expected changed to be %t, got %v", testCase.name, testCase.changed, err)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package direct
+
+import (
+	"context"
+	"downite/types"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// format ids that choose a format by its kind instead of its itag
+const (
+	youtubeFormatBest  = "best"
+	youtubeFormatAudio = "audio"
+)
+
+var youtubeHosts = map[string]bool{
+	"youtube":           true,
+	"youtu.be":          true,
+	"youtube.com":       true,
+	"www.youtube.com":   true,
+	"m.youtube.com":     true,
+	"music.youtube.com": true,
+}
+
+// youtubeResolver resolves youtube video pages. format ids are itags, best or audio
+type youtubeResolver struct{}
+
+func (resolver *youtubeResolver) Name() string {
+	return "youtube"
+}
+
+func (resolver *youtubeResolver) CanResolve(parsedUrl *url.URL) bool {
+	return youtubeHosts[strings.ToLower(parsedUrl.Hostname())]
+}
+
+func (resolver *youtubeResolver) Resolve(ctx context.Context, httpClient *http.Client, rawUrl string, formatId string) ([]types.ResolvedFile, error) {
+	youtubeClient := &youtube.Client{HTTPClient: httpClient}
+	video, err := youtubeClient.GetVideoContext(ctx, rawUrl)
+	if err != nil {
+		return nil, err
+	}
+	format, err := chooseYoutubeFormat(video.Formats, formatId)
+	if err != nil {
+		return nil, err
+	}
+	streamUrl, err := youtubeClient.GetStreamURLContext(ctx, video, format)
+	if err != nil {
+		return nil, err
+	}
+	return []types.ResolvedFile{{
+		Url:  streamUrl,
+		Name: sanitizeFileName(video.Title) + mimeExtension(format.MimeType),
+	}}, nil
+}
+
+func (resolver *youtubeResolver) Formats(ctx context.Context, httpClient *http.Client, rawUrl string) ([]types.ResolvedFormat, error) {
+	youtubeClient := &youtube.Client{HTTPClient: httpClient}
+	video, err := youtubeClient.GetVideoContext(ctx, rawUrl)
+	if err != nil {
+		return nil, err
+	}
+	formats := make([]types.ResolvedFormat, 0, len(video.Formats))
+	for _, format := range video.Formats {
+		quality := format.QualityLabel
+		if quality == "" {
+			quality = format.AudioQuality
+		}
+		formats = append(formats, types.ResolvedFormat{
+			Id:       strconv.Itoa(format.ItagNo),
+			MimeType: format.MimeType,
+			Quality:  quality,
+			HasVideo: strings.HasPrefix(format.MimeType, "video"),
+			HasAudio: format.AudioChannels > 0,
+			Width:    format.Width,
+			Height:   format.Height,
+			Fps:      format.FPS,
+			Bitrate:  format.Bitrate,
+			Size:     uint64(format.ContentLength),
+		})
+	}
+	return formats, nil
+}
+
+// youtube channels list their uploads in a playlist with the id of the channel starting with UU instead of UC
+var (
+	youtubeChannelIdRegex   = regexp.MustCompile(`^UC[\w-]{22}$`)
+	youtubeExternalIdRegex  = regexp.MustCompile(`"externalId":"(UC[\w-]{22})"`)
+	youtubePageChannelRegex = regexp.MustCompile(`"channelId":"(UC[\w-]{22})"`)
+)
+
+// IsPlaylist reports whether the url is a playlist or a channel. video urls in a playlist are playlists too
+func (resolver *youtubeResolver) IsPlaylist(parsedUrl *url.URL) bool {
+	if parsedUrl.Query().Get("list") != "" {
+		return true
+	}
+	segments := strings.Split(strings.Trim(parsedUrl.Path, "/"), "/")
+	switch {
+	case segments[0] == "channel" && len(segments) > 1:
+		return youtubeChannelIdRegex.MatchString(segments[1])
+	case segments[0] == "c" || segments[0] == "user":
+		return len(segments) > 1 && segments[1] != ""
+	}
+	return strings.HasPrefix(segments[0], "@") && len(segments[0]) > 1
+}
+
+func (resolver *youtubeResolver) Playlist(ctx context.Context, httpClient *http.Client, rawUrl string) (*types.Playlist, error) {
+	parsedUrl, err := url.Parse(rawUrl)
+	if err != nil {
+		return nil, err
+	}
+	playlistId := parsedUrl.Query().Get("list")
+	if playlistId == "" {
+		channelId, err := youtubeChannelId(ctx, httpClient, parsedUrl)
+		if err != nil {
+			return nil, err
+		}
+		playlistId = youtubeUploadsPlaylistId(channelId)
+	}
+
+	youtubeClient := &youtube.Client{HTTPClient: httpClient}
+	youtubePlaylist, err := youtubeClient.GetPlaylistContext(ctx, playlistId)
+	if err != nil {
+		return nil, err
+	}
+	playlist := &types.Playlist{
+		Title:   youtubePlaylist.Title,
+		Author:  youtubePlaylist.Author,
+		Entries: make([]types.PlaylistEntry, 0, len(youtubePlaylist.Videos)),
+	}
+	for i, video := range youtubePlaylist.Videos {
+		playlist.Entries = append(playlist.Entries, types.PlaylistEntry{
+			Index:    i + 1,
+			Title:    video.Title,
+			Author:   video.Author,
+			Url:      "https://www.youtube.com/watch?v=" + video.ID,
+			Duration: video.Duration.Seconds(),
+		})
+	}
+	return playlist, nil
+}
+
+// youtubeChannelId returns the id of the channel. handle and custom urls don't have the id so it is read from the channel page
+func youtubeChannelId(ctx context.Context, httpClient *http.Client, parsedUrl *url.URL) (string, error) {
+	segments := strings.Split(strings.Trim(parsedUrl.Path, "/"), "/")
+	if segments[0] == "channel" && len(segments) > 1 && youtubeChannelIdRegex.MatchString(segments[1]) {
+		return segments[1], nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://www.youtube.com/"+strings.Join(segments, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("channel page returned status %d", res.StatusCode)
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+	return youtubeChannelIdFromPage(body)
+}
+
+func youtubeChannelIdFromPage(body []byte) (string, error) {
+	match := youtubeExternalIdRegex.FindSubmatch(body)
+	if match == nil {
+		match = youtubePageChannelRegex.FindSubmatch(body)
+	}
+	if match == nil {
+		return "", fmt.Errorf("channel id not found on the channel page")
+	}
+	return string(match[1]), nil
+}
+
+// youtubeUploadsPlaylistId returns the id of the playlist with all uploads of the channel
+func youtubeUploadsPlaylistId(channelId string) string {
+	return "UU" + strings.TrimPrefix(channelId, "UC")
+}
+
+// chooseYoutubeFormat returns the format with the itag. best is the video with audio with the highest bitrate and audio is the audio only format with the highest bitrate
+func chooseYoutubeFormat(formats youtube.FormatList, formatId string) (*youtube.Format, error) {
+	switch formatId {
+	case "", youtubeFormatBest:
+		videoFormats := FilterFormats(formats, "video")
+		withAudio := make([]youtube.Format, 0)
+		for _, format := range videoFormats {
+			if format.AudioChannels > 0 {
+				withAudio = append(withAudio, format)
+			}
+		}
+		if len(withAudio) > 0 {
+			videoFormats = withAudio
+		}
+		if len(videoFormats) == 0 {
+			return nil, fmt.Errorf("video has no video formats")
+		}
+		format := GetBestHighFormat(videoFormats)
+		return &format, nil
+	case youtubeFormatAudio:
+		audioFormats := FilterFormats(formats, "audio")
+		if len(audioFormats) == 0 {
+			return nil, fmt.Errorf("video has no audio formats")
+		}
+		format := GetBestHighFormat(audioFormats)
+		return &format, nil
+	}
+	itag, err := strconv.Atoi(formatId)
+	if err != nil {
+		return nil, fmt.Errorf("unknown format %s", formatId)
+	}
+	for i := range formats {
+		if formats[i].ItagNo == itag {
+			return &formats[i], nil
+		}
+	}
+	return nil, fmt.Errorf("format %s not found", formatId)
+}
+
+// FilterFormats filters a list of YouTube formats based on a specific kind (e.g. "video/mp4")
+func FilterFormats(formats youtube.FormatList, kind string) []youtube.Format {
+	var filteredFormats []youtube.Format
+	for _, format := range formats {
+		if strings.Contains(format.MimeType, kind) {
+			filteredFormats = append(filteredFormats, format)
+		}
+	}
+	return filteredFormats
+}
+
+// GetBestHighFormat returns the format with the highest bitrate from a list of formats
+func GetBestHighFormat(formats []youtube.Format) youtube.Format {
+	var bestFormat youtube.Format
+	for _, format := range formats {
+		if format.Bitrate > bestFormat.Bitrate {
+			bestFormat = format
+		}
+	}
+	return bestFormat
+}
+
+// mimeExtension returns the file extension of a mime type like video/mp4; codecs="avc1"
+func mimeExtension(mimeType string) string {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return ""
+	}
+	switch mediaType {
+	case "video/mp4":
+		return ".mp4"
+	case "audio/mp4":
+		return ".m4a"
+	case "video/webm", "audio/webm":
+		return ".webm"
+	}
+	extensions, err := mime.ExtensionsByType(mediaType)
+	if err != nil || len(extensions) == 0 {
+		return ""
+	}
+	return extensions[0]
+}
+
+// sanitizeFileName replaces the characters that can't be used in file names
+func sanitizeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`<>:"/\|?*`, r) || r < 32 {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.Trim(name, " .")
+	if name == "" {
+		return "video"
+	}
+	return name
+}
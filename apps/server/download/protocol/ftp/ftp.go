@@ -0,0 +1,241 @@
+package ftp
+
+import (
+	"context"
+	"crypto/tls"
+	"downite/download/proxy"
+	"downite/types"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"net/url"
+	"path"
+	"time"
+
+	goftp "github.com/jlaffaye/ftp"
+)
+
+type FtpClientConfig struct {
+	// timeout for connecting and for every command
+	Timeout time.Duration
+}
+
+// FTP DOWNLOAD CLIENT
+// every transfer opens its own connection so that parts of a download can be fetched at the same time
+type FtpClient struct {
+	Config *FtpClientConfig
+}
+
+func CreateFtpClient(config *FtpClientConfig) *FtpClient {
+	return &FtpClient{
+		Config: config,
+	}
+}
+
+func NewClientDefaultConfig() *FtpClientConfig {
+	return &FtpClientConfig{
+		Timeout: 30 * time.Second,
+	}
+}
+
+// IsFtpUrl reports whether the url is downloaded with ftp. ftps means explicit FTPS
+func IsFtpUrl(rawUrl string) bool {
+	parsedUrl, err := url.Parse(rawUrl)
+	if err != nil {
+		return false
+	}
+	return parsedUrl.Scheme == "ftp" || parsedUrl.Scheme == "ftps"
+}
+
+// SplitCredentials removes the user info like user:pass from ftp urls so that the url can be saved and shown.
+// other urls are returned as they are
+func SplitCredentials(rawUrl string) (string, string) {
+	if !IsFtpUrl(rawUrl) {
+		return rawUrl, ""
+	}
+	parsedUrl, err := url.Parse(rawUrl)
+	if err != nil || parsedUrl.User == nil {
+		return rawUrl, ""
+	}
+	credentials := parsedUrl.User.String()
+	parsedUrl.User = nil
+	return parsedUrl.String(), credentials
+}
+
+// WithCredentials adds the user info removed by SplitCredentials back to the url
+func WithCredentials(rawUrl string, credentials string) string {
+	if credentials == "" {
+		return rawUrl
+	}
+	parsedUrl, err := url.Parse(rawUrl)
+	if err != nil {
+		return rawUrl
+	}
+	userUrl, err := url.Parse("ftp://" + credentials + "@localhost")
+	if err != nil {
+		return rawUrl
+	}
+	parsedUrl.User = userUrl.User
+	return parsedUrl.String()
+}
+
+// connect opens a control connection and logs in. servers are used in passive mode.
+// control and data connections go through the proxy when proxy url is not nil
+func (client *FtpClient) connect(ctx context.Context, parsedUrl *url.URL, proxyUrl *url.URL) (*goftp.ServerConn, error) {
+	host := parsedUrl.Host
+	if parsedUrl.Port() == "" {
+		host = net.JoinHostPort(parsedUrl.Hostname(), "21")
+	}
+
+	dialCtx, cancel := context.WithTimeout(ctx, client.Config.Timeout)
+	defer cancel()
+	options := []goftp.DialOption{
+		goftp.DialWithContext(dialCtx),
+		goftp.DialWithTimeout(client.Config.Timeout),
+	}
+	if proxyUrl != nil {
+		// download must not fall back to a direct connection when the proxy can't be used
+		dialer, err := proxy.NewDialer(proxyUrl)
+		if err != nil {
+			return nil, fmt.Errorf("cannot use proxy for ftp : %s", err)
+		}
+		options = append(options, goftp.DialWithDialFunc(func(network string, address string) (net.Conn, error) {
+			// data connections are opened after connect returns. every connection gets its own timeout
+			connCtx, cancel := context.WithTimeout(ctx, client.Config.Timeout)
+			defer cancel()
+			return dialer.DialContext(connCtx, network, address)
+		}))
+	}
+	if parsedUrl.Scheme == "ftps" {
+		options = append(options, goftp.DialWithExplicitTLS(&tls.Config{
+			ServerName: parsedUrl.Hostname(),
+			// data connections must reuse the session of the control connection on most servers
+			ClientSessionCache: tls.NewLRUClientSessionCache(0),
+		}))
+	}
+
+	conn, err := goftp.Dial(host, options...)
+	if err != nil {
+		return nil, fmt.Errorf("while connecting to ftp server : %w", err)
+	}
+
+	username := "anonymous"
+	password := "anonymous"
+	if parsedUrl.User != nil {
+		username = parsedUrl.User.Username()
+		password, _ = parsedUrl.User.Password()
+	}
+	err = conn.Login(username, password)
+	if err != nil {
+		conn.Quit()
+		return nil, fmt.Errorf("while logging in to ftp server : %w", err)
+	}
+	return conn, nil
+}
+
+// GetDownloadMeta finds the name and the size of the file. range is allowed when the server supports REST
+func (client *FtpClient) GetDownloadMeta(ctx context.Context, rawUrl string, proxyUrl *url.URL) (*types.DownloadMeta, error) {
+	parsedUrl, err := url.Parse(rawUrl)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse url : %s", err)
+	}
+	fileName := path.Base(parsedUrl.Path)
+	if fileName == "" || fileName == "." || fileName == "/" {
+		return nil, fmt.Errorf("cannot find file name")
+	}
+
+	conn, err := client.connect(ctx, parsedUrl, proxyUrl)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Quit()
+
+	totalSize, err := conn.FileSize(parsedUrl.Path)
+	if err != nil {
+		// SIZE is not supported everywhere. MLST gives the size too
+		entry, entryErr := conn.GetEntry(parsedUrl.Path)
+		if entryErr != nil {
+			return nil, fmt.Errorf("cannot find file size : %s", err)
+		}
+		if entry.Type != goftp.EntryTypeFile {
+			return nil, fmt.Errorf("%s is not a file", parsedUrl.Path)
+		}
+		totalSize = int64(entry.Size)
+	}
+
+	return &types.DownloadMeta{
+		FileName:       fileName,
+		TotalSize:      uint64(totalSize),
+		Url:            rawUrl,
+		FileType:       path.Ext(fileName),
+		IsRangeAllowed: client.isRestSupported(conn, parsedUrl.Path, totalSize),
+	}, nil
+}
+
+// isRestSupported tries to read the last byte of the file with REST
+func (client *FtpClient) isRestSupported(conn *goftp.ServerConn, filePath string, totalSize int64) bool {
+	if totalSize == 0 {
+		return false
+	}
+	res, err := conn.RetrFrom(filePath, uint64(totalSize-1))
+	if err != nil {
+		return false
+	}
+	_, err = io.Copy(io.Discard, res)
+	res.Close()
+	return err == nil
+}
+
+// Open starts the transfer of the file from offset. transfer is aborted when the context is canceled or the reader is closed
+func (client *FtpClient) Open(ctx context.Context, rawUrl string, offset uint64, proxyUrl *url.URL) (io.ReadCloser, error) {
+	parsedUrl, err := url.Parse(rawUrl)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse url : %s", err)
+	}
+
+	conn, err := client.connect(ctx, parsedUrl, proxyUrl)
+	if err != nil {
+		return nil, err
+	}
+	// closing the control connection also stops the transfer
+	stop := context.AfterFunc(ctx, func() {
+		conn.Quit()
+	})
+
+	res, err := conn.RetrFrom(parsedUrl.Path, offset)
+	if err != nil {
+		stop()
+		conn.Quit()
+		return nil, fmt.Errorf("while starting ftp transfer : %w", err)
+	}
+	return &transfer{conn: conn, res: res, stop: stop}, nil
+}
+
+type transfer struct {
+	conn *goftp.ServerConn
+	res  *goftp.Response
+	stop func() bool
+}
+
+func (transfer *transfer) Read(buffer []byte) (int, error) {
+	return transfer.res.Read(buffer)
+}
+
+// Close ends the transfer. closing the control connection first doesn't wait for the server to abort an incomplete transfer
+func (transfer *transfer) Close() error {
+	transfer.stop()
+	err := transfer.conn.Quit()
+	transfer.res.Close()
+	return err
+}
+
+// IsTemporaryError reports whether the server rejected the command for now. 4xx replies are temporary in ftp
+func IsTemporaryError(err error) bool {
+	var ftpErr *textproto.Error
+	if errors.As(err, &ftpErr) {
+		return ftpErr.Code >= 400 && ftpErr.Code < 500
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package ftptest
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Server is a minimal passive mode ftp server serving a single file. user and pass are its credentials
+type Server struct {
+	listener net.Listener
+	filePath string
+	data     []byte
+}
+
+// NewServer starts the server. it is closed when the test ends
+func NewServer(t *testing.T, filePath string, data []byte) *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &Server{listener: listener, filePath: filePath, data: data}
+	go server.serve()
+	t.Cleanup(func() {
+		listener.Close()
+	})
+	return server
+}
+
+// Url returns the url of the file with the credentials of the server
+func (server *Server) Url() string {
+	return fmt.Sprintf("ftp://user:pass@%s%s", server.listener.Addr(), server.filePath)
+}
+
+func (server *Server) serve() {
+	for {
+		conn, err := server.listener.Accept()
+		if err != nil {
+			return
+		}
+		go server.handle(conn)
+	}
+}
+
+func (server *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(format string, args ...any) {
+		fmt.Fprintf(conn, format+"\r\n", args...)
+	}
+
+	var dataListener net.Listener
+	var offset int
+	reply("220 ready")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command, argument, _ := strings.Cut(strings.TrimSpace(line), " ")
+		switch strings.ToUpper(command) {
+		case "USER":
+			reply("331 password required")
+		case "PASS":
+			if argument != "pass" {
+				reply("530 login incorrect")
+				continue
+			}
+			reply("230 logged in")
+		case "FEAT":
+			reply("211-Features:\r\n SIZE\r\n REST STREAM\r\n211 End")
+		case "TYPE":
+			reply("200 type set")
+		case "SIZE":
+			if argument != server.filePath {
+				reply("550 file not found")
+				continue
+			}
+			reply("213 %d", len(server.data))
+		case "EPSV":
+			dataListener, err = net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				reply("425 cannot open data connection")
+				continue
+			}
+			reply("229 Entering Extended Passive Mode (|||%d|)", dataListener.Addr().(*net.TCPAddr).Port)
+		case "REST":
+			offset, err = strconv.Atoi(argument)
+			if err != nil {
+				reply("501 invalid offset")
+				continue
+			}
+			reply("350 restarting at %d", offset)
+		case "RETR":
+			if argument != server.filePath || dataListener == nil {
+				reply("550 file not found")
+				continue
+			}
+			dataConn, err := dataListener.Accept()
+			dataListener.Close()
+			dataListener = nil
+			if err != nil {
+				reply("425 cannot open data connection")
+				continue
+			}
+			reply("150 opening data connection")
+			_, err = dataConn.Write(server.data[offset:])
+			dataConn.Close()
+			offset = 0
+			if err != nil {
+				reply("426 transfer aborted")
+				continue
+			}
+			reply("226 transfer complete")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 command not implemented")
+		}
+	}
+}
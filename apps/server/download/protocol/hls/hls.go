@@ -0,0 +1,261 @@
+package hls
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"downite/types"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/grafov/m3u8"
+)
+
+const (
+	EncryptionMethodNone   = "NONE"
+	EncryptionMethodAes128 = "AES-128"
+)
+
+// Key is the EXT-X-KEY of a segment
+type Key struct {
+	Method string
+	Url    string
+	// explicit iv of the key. segments use their sequence number when it is empty
+	IV []byte
+}
+
+// Segment is a media segment with its url resolved against the playlist url
+type Segment struct {
+	Index    int
+	Sequence uint64
+	Url      string
+	Duration float64
+	// EXT-X-BYTERANGE of the segment. length is 0 when the whole resource is the segment
+	Offset int64
+	Length int64
+	// nil when the segment is not encrypted
+	Key *Key
+}
+
+type MediaPlaylist struct {
+	Segments []*Segment
+	Duration float64
+	// live playlists have no EXT-X-ENDLIST. they are still growing
+	IsLive bool
+}
+
+// Playlist is either a master playlist with variants or a media playlist with segments
+type Playlist struct {
+	Variants []types.HlsVariant
+	Media    *MediaPlaylist
+}
+
+// IsHlsUrl reports whether the url points to an m3u8 playlist
+func IsHlsUrl(rawUrl string) bool {
+	parsedUrl, err := url.Parse(rawUrl)
+	if err != nil {
+		return false
+	}
+	if parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https" {
+		return false
+	}
+	return strings.EqualFold(path.Ext(parsedUrl.Path), ".m3u8")
+}
+
+// IsPlaylistContentType reports whether the content type of a response is an m3u8 playlist
+func IsPlaylistContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	mediaType = strings.ToLower(mediaType)
+	return mediaType == "application/vnd.apple.mpegurl" || mediaType == "application/x-mpegurl" || mediaType == "audio/mpegurl"
+}
+
+// FileName returns the name of the .ts file the stream is saved to
+func FileName(rawUrl string) (string, error) {
+	parsedUrl, err := url.Parse(rawUrl)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse url : %s", err)
+	}
+	baseName := strings.TrimSuffix(path.Base(parsedUrl.Path), path.Ext(parsedUrl.Path))
+	if baseName == "" || baseName == "." || baseName == "/" {
+		return "", fmt.Errorf("cannot find file name")
+	}
+	return baseName + ".ts", nil
+}
+
+// ParsePlaylist decodes the playlist and resolves every url in it against playlistUrl
+func ParsePlaylist(reader io.Reader, playlistUrl string) (*Playlist, error) {
+	baseUrl, err := url.Parse(playlistUrl)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse url : %s", err)
+	}
+	decodedPlaylist, listType, err := m3u8.DecodeFrom(reader, false)
+	if err != nil {
+		return nil, fmt.Errorf("while parsing playlist : %s", err)
+	}
+
+	switch listType {
+	case m3u8.MASTER:
+		return parseMasterPlaylist(decodedPlaylist.(*m3u8.MasterPlaylist), baseUrl)
+	case m3u8.MEDIA:
+		mediaPlaylist, err := parseMediaPlaylist(decodedPlaylist.(*m3u8.MediaPlaylist), baseUrl)
+		if err != nil {
+			return nil, err
+		}
+		return &Playlist{Media: mediaPlaylist}, nil
+	}
+	return nil, fmt.Errorf("unknown playlist type")
+}
+
+// parseMasterPlaylist returns the variants with the highest bandwidth first. i-frame only variants are skipped
+func parseMasterPlaylist(masterPlaylist *m3u8.MasterPlaylist, baseUrl *url.URL) (*Playlist, error) {
+	variants := make([]types.HlsVariant, 0, len(masterPlaylist.Variants))
+	for _, variant := range masterPlaylist.Variants {
+		if variant == nil || variant.Iframe {
+			continue
+		}
+		variantUrl, err := baseUrl.Parse(variant.URI)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse variant url %s : %s", variant.URI, err)
+		}
+		variants = append(variants, types.HlsVariant{
+			Url:        variantUrl.String(),
+			Bandwidth:  variant.Bandwidth,
+			Resolution: variant.Resolution,
+			Codecs:     variant.Codecs,
+			Name:       variant.Name,
+		})
+	}
+	if len(variants) == 0 {
+		return nil, fmt.Errorf("master playlist has no variants")
+	}
+	sort.SliceStable(variants, func(i, j int) bool {
+		return variants[i].Bandwidth > variants[j].Bandwidth
+	})
+	return &Playlist{Variants: variants}, nil
+}
+
+// BestVariant returns the variant with the highest bandwidth. variants of the same bandwidth keep their order
+func BestVariant(variants []types.HlsVariant) types.HlsVariant {
+	best := variants[0]
+	for _, variant := range variants[1:] {
+		if variant.Bandwidth > best.Bandwidth {
+			best = variant
+		}
+	}
+	return best
+}
+
+func parseMediaPlaylist(mediaPlaylist *m3u8.MediaPlaylist, baseUrl *url.URL) (*MediaPlaylist, error) {
+	playlist := &MediaPlaylist{
+		Segments: make([]*Segment, 0, mediaPlaylist.Count()),
+		IsLive:   !mediaPlaylist.Closed,
+	}
+
+	// a key applies to every segment after it until the next key
+	var currentKey *Key
+	var nextOffset int64
+	for _, segment := range mediaPlaylist.Segments {
+		if segment == nil {
+			continue
+		}
+		if segment.Map != nil {
+			return nil, fmt.Errorf("fragmented mp4 streams are not supported")
+		}
+		if segment.Key != nil {
+			key, err := parseKey(segment.Key, baseUrl)
+			if err != nil {
+				return nil, err
+			}
+			currentKey = key
+		}
+
+		segmentUrl, err := baseUrl.Parse(segment.URI)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse segment url %s : %s", segment.URI, err)
+		}
+		offset := segment.Offset
+		if segment.Limit > 0 && offset == 0 {
+			// byte range without offset starts where the previous one ended
+			offset = nextOffset
+		}
+		nextOffset = offset + segment.Limit
+
+		playlist.Segments = append(playlist.Segments, &Segment{
+			Index:    len(playlist.Segments),
+			Sequence: segment.SeqId,
+			Url:      segmentUrl.String(),
+			Duration: segment.Duration,
+			Offset:   offset,
+			Length:   segment.Limit,
+			Key:      currentKey,
+		})
+		playlist.Duration += segment.Duration
+	}
+	if len(playlist.Segments) == 0 {
+		return nil, fmt.Errorf("media playlist has no segments")
+	}
+	return playlist, nil
+}
+
+func parseKey(key *m3u8.Key, baseUrl *url.URL) (*Key, error) {
+	method := strings.ToUpper(key.Method)
+	if method == EncryptionMethodNone || method == "" {
+		return nil, nil
+	}
+	if method != EncryptionMethodAes128 {
+		return nil, fmt.Errorf("unsupported encryption method : %s", key.Method)
+	}
+
+	keyUrl, err := baseUrl.Parse(key.URI)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse key url %s : %s", key.URI, err)
+	}
+	var iv []byte
+	if key.IV != "" {
+		iv, err = hex.DecodeString(strings.TrimPrefix(strings.TrimPrefix(key.IV, "0x"), "0X"))
+		if err != nil || len(iv) != aes.BlockSize {
+			return nil, fmt.Errorf("invalid key iv : %s", key.IV)
+		}
+	}
+	return &Key{Method: method, Url: keyUrl.String(), IV: iv}, nil
+}
+
+// IV returns the iv used to decrypt the segment. without an explicit iv it is the media sequence number of the segment
+func (segment *Segment) IV() []byte {
+	if segment.Key != nil && segment.Key.IV != nil {
+		return segment.Key.IV
+	}
+	iv := make([]byte, aes.BlockSize)
+	binary.BigEndian.PutUint64(iv[aes.BlockSize-8:], segment.Sequence)
+	return iv
+}
+
+// DecryptSegment decrypts an AES-128 encrypted segment and removes its PKCS7 padding
+func DecryptSegment(data []byte, key []byte, iv []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key : %s", err)
+	}
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("encrypted segment length %d is not a multiple of the block size", len(data))
+	}
+
+	decrypted := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(decrypted, data)
+
+	padding := int(decrypted[len(decrypted)-1])
+	if padding == 0 || padding > aes.BlockSize || !bytes.Equal(decrypted[len(decrypted)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		return nil, fmt.Errorf("invalid padding in decrypted segment")
+	}
+	return decrypted[:len(decrypted)-padding], nil
+}
@@ -0,0 +1,139 @@
+package hls
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"downite/types"
+	"strings"
+	"testing"
+)
+
+func TestParseMasterPlaylist(t *testing.T) {
+	master := `#EXTM3U
+#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360
+low/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=2400000,RESOLUTION=1280x720,CODECS="avc1.4d401f,mp4a.40.2"
+https://cdn.example.com/high/index.m3u8
+`
+	playlist, err := ParsePlaylist(strings.NewReader(master), "https://example.com/video/master.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if playlist.Media != nil || len(playlist.Variants) != 2 {
+		t.Fatalf("expected 2 variants, got %+v", playlist)
+	}
+	if playlist.Variants[0].Url != "https://cdn.example.com/high/index.m3u8" || playlist.Variants[0].Resolution != "1280x720" {
+		t.Errorf("highest bandwidth variant is not first : %+v", playlist.Variants[0])
+	}
+	if playlist.Variants[1].Url != "https://example.com/video/low/index.m3u8" {
+		t.Errorf("relative variant url is not resolved : %s", playlist.Variants[1].Url)
+	}
+}
+
+func TestBestVariant(t *testing.T) {
+	variants := []types.HlsVariant{
+		{Url: "low.m3u8", Bandwidth: 800000},
+		{Url: "high.m3u8", Bandwidth: 2400000},
+		{Url: "high-backup.m3u8", Bandwidth: 2400000},
+		{Url: "medium.m3u8", Bandwidth: 1200000},
+	}
+	if best := BestVariant(variants); best.Url != "high.m3u8" {
+		t.Errorf("expected the first variant with the highest bandwidth, got %s", best.Url)
+	}
+}
+
+func TestParseMediaPlaylist(t *testing.T) {
+	media := `#EXTM3U
+#EXT-X-TARGETDURATION:10
+#EXT-X-MEDIA-SEQUENCE:7
+#EXTINF:10,
+segment0.ts
+#EXT-X-KEY:METHOD=AES-128,URI="key.bin"
+#EXTINF:10,
+segment1.ts
+#EXT-X-KEY:METHOD=AES-128,URI="/keys/other.bin",IV=0x000102030405060708090a0b0c0d0e0f
+#EXT-X-BYTERANGE:100@50
+#EXTINF:5.5,
+segment2.ts
+#EXT-X-ENDLIST
+`
+	playlist, err := ParsePlaylist(strings.NewReader(media), "https://example.com/video/index.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if playlist.Media == nil || len(playlist.Media.Segments) != 3 {
+		t.Fatalf("expected 3 segments, got %+v", playlist)
+	}
+	if playlist.Media.IsLive || playlist.Media.Duration != 25.5 {
+		t.Errorf("unexpected playlist %+v", playlist.Media)
+	}
+
+	segments := playlist.Media.Segments
+	if segments[0].Key != nil || segments[0].Url != "https://example.com/video/segment0.ts" {
+		t.Errorf("unexpected first segment %+v", segments[0])
+	}
+	if segments[1].Key == nil || segments[1].Key.Url != "https://example.com/video/key.bin" {
+		t.Errorf("unexpected key of second segment %+v", segments[1].Key)
+	}
+	expectedIV := make([]byte, aes.BlockSize)
+	expectedIV[aes.BlockSize-1] = 8
+	if !bytes.Equal(segments[1].IV(), expectedIV) {
+		t.Errorf("iv of second segment is %x, want media sequence %x", segments[1].IV(), expectedIV)
+	}
+	if segments[2].Key.Url != "https://example.com/keys/other.bin" || segments[2].IV()[15] != 0x0f {
+		t.Errorf("unexpected key of third segment %+v", segments[2].Key)
+	}
+	if segments[2].Offset != 50 || segments[2].Length != 100 {
+		t.Errorf("unexpected byte range %d@%d", segments[2].Length, segments[2].Offset)
+	}
+}
+
+func TestParseLivePlaylist(t *testing.T) {
+	media := "#EXTM3U\n#EXT-X-TARGETDURATION:10\n#EXTINF:10,\nsegment0.ts\n"
+	playlist, err := ParsePlaylist(strings.NewReader(media), "https://example.com/live.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !playlist.Media.IsLive {
+		t.Errorf("playlist without EXT-X-ENDLIST should be live")
+	}
+}
+
+func TestDecryptSegment(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	iv := []byte("fedcba9876543210")
+	data := []byte("downite hls segment")
+
+	// PKCS7 padding
+	padding := aes.BlockSize - len(data)%aes.BlockSize
+	padded := append(append([]byte{}, data...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, padded)
+
+	decrypted, err := DecryptSegment(encrypted, key, iv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, data) {
+		t.Errorf("decrypted %q, want %q", decrypted, data)
+	}
+
+	_, err = DecryptSegment(encrypted[:len(encrypted)-1], key, iv)
+	if err == nil {
+		t.Errorf("expected error for truncated segment")
+	}
+}
+
+func TestIsHlsUrl(t *testing.T) {
+	if !IsHlsUrl("https://example.com/stream/index.M3U8?token=1") {
+		t.Errorf("m3u8 url is not detected")
+	}
+	if IsHlsUrl("https://example.com/file.ts") || IsHlsUrl("ftp://example.com/index.m3u8") {
+		t.Errorf("non hls url is detected as hls")
+	}
+}
@@ -0,0 +1,122 @@
+package torr
+
+import (
+	"downite/download/proxy"
+	"downite/types"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	gotorrent "github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// torrent files bigger than this are not loaded
+const maxTorrentFileSize = 32 * 1024 * 1024
+
+// fetchTorrentSpec loads the torrent of a magnet link or of a torrent file url. torrent files are fetched through the proxy of the engine
+func (torrentEngine *TorrentEngine) fetchTorrentSpec(torrentUrl string) (*gotorrent.TorrentSpec, error) {
+	if strings.HasPrefix(torrentUrl, "magnet:") {
+		return gotorrent.TorrentSpecFromMagnetUri(torrentUrl)
+	}
+
+	proxyUrl, err := proxy.ParseUrl(torrentEngine.Config.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	res, err := proxy.NewHttpClient(proxyUrl).Get(torrentUrl)
+	if err != nil {
+		return nil, fmt.Errorf("while getting torrent file : %s", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code while getting torrent file: %s", res.Status)
+	}
+
+	torrentMeta, err := metainfo.Load(io.LimitReader(res.Body, maxTorrentFileSize))
+	if err != nil {
+		return nil, fmt.Errorf("while loading torrent file : %s", err)
+	}
+	return gotorrent.TorrentSpecFromMetaInfoErr(torrentMeta)
+}
+
+// DownloadTorrentFromUrl adds the torrent of a magnet link or a torrent file url with every file of it wanted.
+// magnet links don't wait for their info from peers. their files are added when the info arrives
+func (torrentEngine *TorrentEngine) DownloadTorrentFromUrl(torrentUrl string, savePath string, startTorrent bool, addTopOfQueue bool) (*types.Torrent, error) {
+	torrentSpec, err := torrentEngine.fetchTorrentSpec(torrentUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	dbTorrent, err := torrentEngine.RegisterTorrent(torrentSpec.InfoHash.String(), torrentSpec.DisplayName, savePath, torrentSpec.Trackers, addTopOfQueue)
+	if err != nil {
+		return nil, err
+	}
+	torrent, err := torrentEngine.addTorrentSpecWithoutInfo(&gotorrent.TorrentSpec{
+		InfoHash:    torrentSpec.InfoHash,
+		InfoBytes:   torrentSpec.InfoBytes,
+		DisplayName: torrentSpec.DisplayName,
+	}, dbTorrent.Trackers, dbTorrent.SavePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// info of torrent files is known
+	if torrent.Info() != nil {
+		err = torrentEngine.addTorrentFiles(torrent, dbTorrent, startTorrent)
+		if err != nil {
+			return nil, err
+		}
+		return dbTorrent, nil
+	}
+	go func() {
+		select {
+		case <-torrent.GotInfo():
+		case <-torrent.Closed():
+			return
+		}
+		err := torrentEngine.addTorrentFiles(torrent, dbTorrent, startTorrent)
+		if err != nil {
+			fmt.Printf("Error while adding files of torrent %s : %s \n", dbTorrent.Name, err)
+		}
+	}()
+	return dbTorrent, nil
+}
+
+// addTorrentFiles wants every file of the torrent and starts it. info of the torrent must be known
+func (torrentEngine *TorrentEngine) addTorrentFiles(torrent *gotorrent.Torrent, dbTorrent *types.Torrent, startTorrent bool) error {
+	torrent.VerifyData()
+
+	files := make([]types.TorrentFileFlatTreeNode, 0, len(torrent.Files()))
+	for _, file := range torrent.Files() {
+		files = append(files, types.TorrentFileFlatTreeNode{
+			Path:     file.DisplayPath(),
+			Priority: "normal",
+		})
+	}
+	_, err := torrentEngine.RegisterFiles(torrent.InfoHash(), &files)
+	if err != nil {
+		return err
+	}
+
+	if startTorrent {
+		torrent, err = torrentEngine.StartTorrent(torrent)
+		if err != nil {
+			return err
+		}
+		dbTorrent.Status = types.TorrentStatusDownloading.String()
+	} else {
+		dbTorrent.Status = types.TorrentStatusPaused.String()
+	}
+
+	dbTorrent.TotalSize = torrent.Length()
+	torrentMetaInfo := torrent.Metainfo()
+	magnetLink, err := torrentMetaInfo.MagnetV2()
+	if err != nil {
+		return err
+	}
+	dbTorrent.Magnet = magnetLink.String()
+
+	return torrentEngine.db.UpdateTorrent(dbTorrent)
+}
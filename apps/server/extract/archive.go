@@ -0,0 +1,301 @@
+package extract
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/bzip2"
+	"compress/gzip"
+	"downite/types"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ulikunitz/xz"
+)
+
+type archiveFormat int
+
+const (
+	archiveFormatZip archiveFormat = iota
+	archiveFormatTar
+	archiveFormatTarGz
+	archiveFormatTarBz2
+	archiveFormatTarXz
+)
+
+// extensions of the supported archives. longer extensions are checked first
+var archiveExtensions = []struct {
+	extension string
+	format    archiveFormat
+}{
+	{".tar.gz", archiveFormatTarGz},
+	{".tar.bz2", archiveFormatTarBz2},
+	{".tar.xz", archiveFormatTarXz},
+	{".tgz", archiveFormatTarGz},
+	{".tbz2", archiveFormatTarBz2},
+	{".txz", archiveFormatTarXz},
+	{".tar", archiveFormatTar},
+	{".zip", archiveFormatZip},
+}
+
+// detectArchive returns the format of the archive and its name without the extension
+func detectArchive(path string) (archiveFormat, string, bool) {
+	name := filepath.Base(path)
+	lowerName := strings.ToLower(name)
+	for _, archiveExtension := range archiveExtensions {
+		if strings.HasSuffix(lowerName, archiveExtension.extension) && len(name) > len(archiveExtension.extension) {
+			return archiveExtension.format, name[:len(name)-len(archiveExtension.extension)], true
+		}
+	}
+	return 0, "", false
+}
+
+// extractionLimits stops extracting archives whose files don't fit on the disk or are far larger than the archive (zip bombs)
+type extractionLimits struct {
+	// -1 means unlimited
+	maxBytes     int64
+	maxFiles     int
+	writtenBytes int64
+	files        int
+}
+
+// newExtractionLimits returns the smallest of the free space of the directory and the limits in settings
+func newExtractionLimits(archivePath string, dir string, settings types.ExtractionSettings) (*extractionLimits, error) {
+	limits := &extractionLimits{maxBytes: -1, maxFiles: -1}
+	if settings.MaxFiles > 0 {
+		limits.maxFiles = settings.MaxFiles
+	}
+	limitBytes := func(maxBytes int64) {
+		if limits.maxBytes < 0 || maxBytes < limits.maxBytes {
+			limits.maxBytes = maxBytes
+		}
+	}
+	if free, ok := freeSpace(dir); ok {
+		limitBytes(free)
+	}
+	if settings.MaxSize > 0 {
+		limitBytes(int64(settings.MaxSize) * 1024 * 1024)
+	}
+	if settings.MaxRatio > 0 {
+		fileInfo, err := os.Stat(archivePath)
+		if err != nil {
+			return nil, err
+		}
+		limitBytes(fileInfo.Size() * int64(settings.MaxRatio))
+	}
+	return limits, nil
+}
+
+// checkEntry counts the entry and checks its declared size before it is written
+func (limits *extractionLimits) checkEntry(name string, size int64) error {
+	limits.files++
+	if limits.maxFiles >= 0 && limits.files > limits.maxFiles {
+		return fmt.Errorf("archive has more than %d files", limits.maxFiles)
+	}
+	return limits.checkBytes(name, limits.writtenBytes+size)
+}
+
+func (limits *extractionLimits) checkBytes(name string, size int64) error {
+	if limits.maxBytes >= 0 && size > limits.maxBytes {
+		return fmt.Errorf("extracted files exceed the limit of %d bytes at %s", limits.maxBytes, name)
+	}
+	return nil
+}
+
+// limitReader stops reading one byte after the limit so that writeFile notices entries larger than declared
+func (limits *extractionLimits) limitReader(content io.Reader) io.Reader {
+	if limits.maxBytes < 0 {
+		return content
+	}
+	return io.LimitReader(content, limits.maxBytes-limits.writtenBytes+1)
+}
+
+// extractArchive extracts the archive into the directory. onProgress is called with the read bytes of the archive.
+// links in archives are skipped so that they can't be used to write outside of the directory
+func extractArchive(archivePath string, format archiveFormat, dir string, settings types.ExtractionSettings, onProgress func(readBytes int64, totalBytes int64)) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+	limits, err := newExtractionLimits(archivePath, dir, settings)
+	if err != nil {
+		return err
+	}
+	if format == archiveFormatZip {
+		return extractZip(archivePath, dir, limits, onProgress)
+	}
+	return extractTar(archivePath, format, dir, limits, onProgress)
+}
+
+func extractZip(archivePath string, dir string, limits *extractionLimits, onProgress func(readBytes int64, totalBytes int64)) error {
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	// declared sizes are checked before anything is extracted. writeFile still checks the written bytes
+	var totalBytes, readBytes, declaredBytes int64
+	for _, file := range reader.File {
+		totalBytes += int64(file.CompressedSize64)
+		declaredBytes += int64(file.UncompressedSize64)
+	}
+	if limits.maxFiles >= 0 && len(reader.File) > limits.maxFiles {
+		return fmt.Errorf("archive has more than %d files", limits.maxFiles)
+	}
+	err = limits.checkBytes(filepath.Base(archivePath), declaredBytes)
+	if err != nil {
+		return err
+	}
+	for _, file := range reader.File {
+		target, err := safeJoin(dir, file.Name)
+		if err != nil {
+			return err
+		}
+		err = limits.checkEntry(file.Name, int64(file.UncompressedSize64))
+		if err != nil {
+			return err
+		}
+		switch {
+		case file.FileInfo().IsDir():
+			err = os.MkdirAll(target, 0755)
+		case file.Mode().IsRegular():
+			var content io.ReadCloser
+			content, err = file.Open()
+			if err != nil {
+				return err
+			}
+			err = writeFile(target, content, file.Mode(), limits)
+			content.Close()
+		default:
+			fmt.Printf("skipping %s in archive, only files and directories are extracted \n", file.Name)
+		}
+		if err != nil {
+			return err
+		}
+		readBytes += int64(file.CompressedSize64)
+		onProgress(readBytes, totalBytes)
+	}
+	return nil
+}
+
+func extractTar(archivePath string, format archiveFormat, dir string, limits *extractionLimits, onProgress func(readBytes int64, totalBytes int64)) error {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	counter := &countingReader{reader: file}
+
+	var reader io.Reader = counter
+	switch format {
+	case archiveFormatTarGz:
+		gzipReader, err := gzip.NewReader(counter)
+		if err != nil {
+			return err
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	case archiveFormatTarBz2:
+		reader = bzip2.NewReader(counter)
+	case archiveFormatTarXz:
+		reader, err = xz.NewReader(counter)
+		if err != nil {
+			return err
+		}
+	}
+
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		target, err := safeJoin(dir, header.Name)
+		if err != nil {
+			return err
+		}
+		if header.Typeflag == tar.TypeDir || header.Typeflag == tar.TypeReg {
+			err = limits.checkEntry(header.Name, header.Size)
+			if err != nil {
+				return err
+			}
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, 0755)
+		case tar.TypeReg:
+			err = writeFile(target, tarReader, header.FileInfo().Mode(), limits)
+		case tar.TypeXGlobalHeader:
+			// pax headers are not entries
+		default:
+			fmt.Printf("skipping %s in archive, only files and directories are extracted \n", header.Name)
+		}
+		if err != nil {
+			return err
+		}
+		onProgress(counter.readBytes, fileInfo.Size())
+	}
+	return nil
+}
+
+// safeJoin returns the path of the archive entry in the directory. entries escaping the directory like ../../.bashrc are rejected (zip slip)
+func safeJoin(dir string, name string) (string, error) {
+	// archives created on windows can use backslashes
+	slashedName := strings.ReplaceAll(name, `\`, "/")
+	if strings.HasPrefix(slashedName, "/") || filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("archive entry has an absolute path : %s", name)
+	}
+	target := filepath.Join(dir, filepath.FromSlash(slashedName))
+	relativePath, err := filepath.Rel(dir, target)
+	if err != nil || relativePath == ".." || strings.HasPrefix(relativePath, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("archive entry is outside of the extraction folder : %s", name)
+	}
+	return target, nil
+}
+
+// writeFile writes the entry and adds its size to the limits. entries exceeding the limits are removed
+func writeFile(target string, content io.Reader, mode os.FileMode, limits *extractionLimits) error {
+	err := os.MkdirAll(filepath.Dir(target), 0755)
+	if err != nil {
+		return err
+	}
+	permission := mode.Perm() | 0600
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, permission)
+	if err != nil {
+		return err
+	}
+	writtenBytes, err := io.Copy(file, limits.limitReader(content))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	limits.writtenBytes += writtenBytes
+	err = limits.checkBytes(filepath.Base(target), limits.writtenBytes)
+	if err != nil {
+		os.Remove(target)
+	}
+	return err
+}
+
+type countingReader struct {
+	reader    io.Reader
+	readBytes int64
+}
+
+func (counter *countingReader) Read(p []byte) (int, error) {
+	n, err := counter.reader.Read(p)
+	counter.readBytes += int64(n)
+	return n, err
+}
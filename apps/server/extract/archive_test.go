@@ -0,0 +1,176 @@
+package extract
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"downite/types"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/ulikunitz/xz"
+)
+
+func TestDetectArchive(t *testing.T) {
+	testCases := []struct {
+		path   string
+		format archiveFormat
+		name   string
+		ok     bool
+	}{
+		{"/downloads/file.zip", archiveFormatZip, "file", true},
+		{"/downloads/file.v2.TAR.GZ", archiveFormatTarGz, "file.v2", true},
+		{"file.tbz2", archiveFormatTarBz2, "file", true},
+		{"file.tar.xz", archiveFormatTarXz, "file", true},
+		{"file.gz", 0, "", false},
+		{".zip", 0, "", false},
+	}
+	for _, testCase := range testCases {
+		format, name, ok := detectArchive(testCase.path)
+		if ok != testCase.ok || format != testCase.format || name != testCase.name {
+			t.Errorf("%s : expected %d %q %t, got %d %q %t", testCase.path, testCase.format, testCase.name, testCase.ok, format, name, ok)
+		}
+	}
+}
+
+func TestSafeJoin(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "extract")
+	for _, name := range []string{"../evil", "a/../../evil", `..\evil`, "/etc/passwd"} {
+		_, err := safeJoin(dir, name)
+		if err == nil {
+			t.Errorf("expected %s to be rejected", name)
+		}
+	}
+	target, err := safeJoin(dir, "a/../b/file")
+	if err != nil || target != filepath.Join(dir, "b", "file") {
+		t.Errorf("unexpected path %s %v", target, err)
+	}
+}
+
+func TestExtractZipSlip(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "slip.zip")
+	file, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := zip.NewWriter(file)
+	for _, name := range []string{"good.txt", "../evil.txt"} {
+		entry, err := writer.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry.Write([]byte(name))
+	}
+	writer.Close()
+	file.Close()
+
+	err = extractArchive(archivePath, archiveFormatZip, filepath.Join(dir, "slip"), types.ExtractionSettings{}, func(readBytes int64, totalBytes int64) {})
+	if err == nil {
+		t.Errorf("expected zip slip to be rejected")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "evil.txt")); !os.IsNotExist(err) {
+		t.Errorf("file outside of extraction folder is written")
+	}
+}
+
+func TestExtractTarXz(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "archive.tar.xz")
+	file, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xzWriter, err := xz.NewWriter(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tarWriter := tar.NewWriter(xzWriter)
+	content := []byte("downite extraction test")
+	tarWriter.WriteHeader(&tar.Header{Name: "folder/", Typeflag: tar.TypeDir, Mode: 0755})
+	tarWriter.WriteHeader(&tar.Header{Name: "folder/file.txt", Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))})
+	tarWriter.Write(content)
+	// links are skipped
+	tarWriter.WriteHeader(&tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"})
+	tarWriter.Close()
+	xzWriter.Close()
+	file.Close()
+
+	var lastProgress, total int64
+	extractPath := filepath.Join(dir, "archive")
+	err = extractArchive(archivePath, archiveFormatTarXz, extractPath, types.ExtractionSettings{}, func(readBytes int64, totalBytes int64) {
+		lastProgress, total = readBytes, totalBytes
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	extracted, err := os.ReadFile(filepath.Join(extractPath, "folder", "file.txt"))
+	if err != nil || string(extracted) != string(content) {
+		t.Errorf("unexpected extracted file %q %v", extracted, err)
+	}
+	if _, err := os.Lstat(filepath.Join(extractPath, "link")); !os.IsNotExist(err) {
+		t.Errorf("expected link to be skipped")
+	}
+	if total == 0 || lastProgress == 0 {
+		t.Errorf("expected progress to be reported")
+	}
+}
+
+func TestExtractZipBomb(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "bomb.zip")
+	file, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := zip.NewWriter(file)
+	for _, name := range []string{"zeros1", "zeros2"} {
+		entry, err := writer.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry.Write(bytes.Repeat([]byte{0}, 1024*1024))
+	}
+	writer.Close()
+	file.Close()
+
+	testCases := []struct {
+		settings types.ExtractionSettings
+		valid    bool
+	}{
+		{types.ExtractionSettings{}, true},
+		{types.ExtractionSettings{MaxRatio: 10}, false},
+		{types.ExtractionSettings{MaxSize: 1}, false},
+		{types.ExtractionSettings{MaxSize: 2, MaxFiles: 2}, true},
+		{types.ExtractionSettings{MaxFiles: 1}, false},
+	}
+	for i, testCase := range testCases {
+		extractPath := filepath.Join(dir, "bomb"+strconv.Itoa(i))
+		err = extractArchive(archivePath, archiveFormatZip, extractPath, testCase.settings, func(readBytes int64, totalBytes int64) {})
+		if (err == nil) != testCase.valid {
+			t.Errorf("expected extraction with %v to succeed %t, got %v", testCase.settings, testCase.valid, err)
+		}
+		if _, statErr := os.Stat(filepath.Join(extractPath, "zeros1")); (statErr == nil) != testCase.valid {
+			t.Errorf("expected files to be extracted %t with %v", testCase.valid, testCase.settings)
+		}
+	}
+}
+
+func TestExtractionLimitsWrittenBytes(t *testing.T) {
+	// entries larger than they declare are stopped while they are written
+	limits := &extractionLimits{maxBytes: 10, maxFiles: -1}
+	target := filepath.Join(t.TempDir(), "file")
+	err := limits.checkEntry("file", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writeFile(target, bytes.NewReader(make([]byte, 100)), 0644, limits)
+	if err == nil {
+		t.Errorf("expected entry exceeding the limit to fail")
+	}
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Errorf("expected entry exceeding the limit to be removed")
+	}
+}
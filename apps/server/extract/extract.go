@@ -0,0 +1,253 @@
+package extract
+
+import (
+	"database/sql"
+	"downite/db"
+	"downite/types"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// progress of extractions is saved to db in this interval
+const progressInterval = time.Second
+
+// events are dropped when this many of them are waiting
+const eventBufferSize = 100
+
+// Extractor extracts the archives of completed transfers. events are passed to the next listener after their archives are extracted
+type Extractor struct {
+	db               *db.Database
+	settings         types.ExtractionSettings
+	mutexForSettings sync.Mutex
+	// next runs the completion rules of the event and returns the event with the path after them
+	next                func(event types.CompletionEvent) types.CompletionEvent
+	events              chan types.CompletionEvent
+	stop                chan struct{}
+	mutexForExtractions sync.Mutex
+	// extractions that are running. their progress is newer than db
+	extractions map[int]*types.Extraction
+}
+
+func CreateExtractor(db *db.Database, settings types.ExtractionSettings, next func(event types.CompletionEvent) types.CompletionEvent) *Extractor {
+	return &Extractor{
+		db:          db,
+		settings:    settings,
+		next:        next,
+		events:      make(chan types.CompletionEvent, eventBufferSize),
+		stop:        make(chan struct{}),
+		extractions: make(map[int]*types.Extraction),
+	}
+}
+
+// InitExtractions fails the extractions stopped by closing the app and starts extracting completed transfers
+func (extractor *Extractor) InitExtractions() error {
+	err := extractor.db.FailUnfinishedExtractions("extraction is stopped by closing the app")
+	if err != nil {
+		return err
+	}
+	go extractor.run()
+	return nil
+}
+
+func (extractor *Extractor) Stop() {
+	close(extractor.stop)
+}
+
+func (extractor *Extractor) SetSettings(settings types.ExtractionSettings) {
+	extractor.mutexForSettings.Lock()
+	defer extractor.mutexForSettings.Unlock()
+	extractor.settings = settings
+}
+
+func (extractor *Extractor) getSettings() types.ExtractionSettings {
+	extractor.mutexForSettings.Lock()
+	defer extractor.mutexForSettings.Unlock()
+	return extractor.settings
+}
+
+// Consume queues the event. it is the completion listener of the engines so it doesn't block them
+func (extractor *Extractor) Consume(event types.CompletionEvent) {
+	select {
+	case extractor.events <- event:
+	default:
+		fmt.Printf("Extraction is skipped for %s, too many waiting transfers \n", event.Path)
+		if extractor.next != nil {
+			go extractor.next(event)
+		}
+	}
+}
+
+func (extractor *Extractor) run() {
+	for {
+		select {
+		case <-extractor.stop:
+			return
+		case event := <-extractor.events:
+			settings := extractor.getSettings()
+			extractions := make([]*types.Extraction, 0)
+			if settings.IsEnabled {
+				extractions = extractor.extractTransfer(event, settings)
+			}
+			if extractor.next != nil {
+				event = extractor.next(event)
+			}
+			// archives are deleted after the completion rules so that the rules can still use them
+			if settings.IsEnabled && settings.DeleteArchive {
+				extractor.deleteArchive(event, extractions)
+			}
+		}
+	}
+}
+
+// extractTransfer extracts the downloaded file or every archive in the torrent's files. it returns the extractions
+func (extractor *Extractor) extractTransfer(event types.CompletionEvent, settings types.ExtractionSettings) []*types.Extraction {
+	extractions := make([]*types.Extraction, 0)
+	archivePaths, err := findArchives(event.Path)
+	if err != nil {
+		fmt.Printf("Error while looking for archives in %s : %s \n", event.Path, err)
+		return extractions
+	}
+	for _, archivePath := range archivePaths {
+		extraction, err := extractor.extract(event, archivePath, settings)
+		if err != nil {
+			fmt.Printf("Error while extracting %s : %s \n", archivePath, err)
+		}
+		if extraction != nil {
+			extractions = append(extractions, extraction)
+		}
+	}
+	return extractions
+}
+
+// deleteArchive deletes the extracted archive of a download. the archive is the downloaded file so the path of the event
+// after the completion rules is deleted. files of torrents are still seeded
+func (extractor *Extractor) deleteArchive(event types.CompletionEvent, extractions []*types.Extraction) {
+	if event.DownloadId == 0 {
+		return
+	}
+	for _, extraction := range extractions {
+		if extraction.Status != types.ExtractionStatusCompleted.String() {
+			continue
+		}
+		err := os.Remove(event.Path)
+		if err != nil {
+			fmt.Printf("Error while deleting extracted archive : %s \n", err)
+			continue
+		}
+		extraction.IsArchiveDeleted = true
+		extractor.saveExtraction(extraction)
+	}
+}
+
+func findArchives(path string) ([]string, error) {
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	archivePaths := make([]string, 0)
+	if !fileInfo.IsDir() {
+		if _, _, ok := detectArchive(path); ok {
+			archivePaths = append(archivePaths, path)
+		}
+		return archivePaths, nil
+	}
+	err = filepath.WalkDir(path, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if _, _, ok := detectArchive(filePath); ok && entry.Type().IsRegular() {
+			archivePaths = append(archivePaths, filePath)
+		}
+		return nil
+	})
+	return archivePaths, err
+}
+
+// extract extracts the archive into a folder named after it
+func (extractor *Extractor) extract(event types.CompletionEvent, archivePath string, settings types.ExtractionSettings) (*types.Extraction, error) {
+	format, name, _ := detectArchive(archivePath)
+	extractFolder := settings.ExtractPath
+	if extractFolder == "" {
+		extractFolder = filepath.Dir(archivePath)
+	}
+	extraction := &types.Extraction{
+		CreatedAt:       time.Now(),
+		DownloadId:      event.DownloadId,
+		TorrentInfohash: event.TorrentInfohash,
+		ArchivePath:     archivePath,
+		ExtractPath:     filepath.Join(extractFolder, name),
+		Status:          types.ExtractionStatusExtracting.String(),
+	}
+	id, err := extractor.db.InsertExtraction(extraction)
+	if err != nil {
+		return nil, err
+	}
+	extraction.Id = id
+	extractor.mutexForExtractions.Lock()
+	extractor.extractions[id] = extraction
+	extractor.mutexForExtractions.Unlock()
+
+	fmt.Printf("extracting archive : %s \n", archivePath)
+	lastSave := time.Now()
+	err = extractArchive(archivePath, format, extraction.ExtractPath, settings, func(readBytes int64, totalBytes int64) {
+		if totalBytes == 0 {
+			return
+		}
+		extractor.mutexForExtractions.Lock()
+		extraction.Progress = float32(readBytes) / float32(totalBytes) * 100
+		extractor.mutexForExtractions.Unlock()
+		if time.Since(lastSave) >= progressInterval {
+			lastSave = time.Now()
+			extractor.saveExtraction(extraction)
+		}
+	})
+
+	extractor.mutexForExtractions.Lock()
+	if err != nil {
+		extraction.Status = types.ExtractionStatusError.String()
+		extraction.Error = err.Error()
+	} else {
+		extraction.Status = types.ExtractionStatusCompleted.String()
+		extraction.Progress = 100
+		fmt.Printf("archive extracted : %s \n", extraction.ExtractPath)
+	}
+	extraction.FinishedAt = sql.NullTime{
+		Time:  time.Now(),
+		Valid: true,
+	}
+	delete(extractor.extractions, id)
+	extractor.mutexForExtractions.Unlock()
+
+	extractor.saveExtraction(extraction)
+	return extraction, err
+}
+
+func (extractor *Extractor) saveExtraction(extraction *types.Extraction) {
+	extractor.mutexForExtractions.Lock()
+	defer extractor.mutexForExtractions.Unlock()
+	err := extractor.db.UpdateExtraction(extraction)
+	if err != nil {
+		fmt.Printf("Error while updating extraction in db : %s \n", err)
+	}
+}
+
+// GetExtractions returns the extractions of the download or the torrent. progress of running extractions is up to date
+func (extractor *Extractor) GetExtractions(downloadId int, torrentInfohash string) ([]*types.Extraction, error) {
+	extractions, err := extractor.db.GetExtractions(downloadId, torrentInfohash)
+	if err != nil {
+		return nil, err
+	}
+	extractor.mutexForExtractions.Lock()
+	defer extractor.mutexForExtractions.Unlock()
+	for i, extraction := range extractions {
+		if running, ok := extractor.extractions[extraction.Id]; ok {
+			runningCopy := *running
+			extractions[i] = &runningCopy
+		}
+	}
+	return extractions, nil
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package extract
+
+// freeSpace is unknown on this platform so only the limits in settings apply
+func freeSpace(dir string) (int64, bool) {
+	return 0, false
+}
@@ -0,0 +1,15 @@
+//go:build linux || darwin
+
+package extract
+
+import "syscall"
+
+// freeSpace returns the bytes available to the user on the disk of the directory
+func freeSpace(dir string) (int64, bool) {
+	var stat syscall.Statfs_t
+	err := syscall.Statfs(dir, &stat)
+	if err != nil {
+		return 0, false
+	}
+	return int64(stat.Bavail) * int64(stat.Bsize), true
+}
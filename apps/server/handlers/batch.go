@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"downite/download/protocol/direct"
+	"downite/types"
+	"io"
+	"mime/multipart"
+	"strconv"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+// url files bigger than this are rejected
+const maxUrlFileSize = 10 * 1024 * 1024
+
+// batches are named like this when no name is given
+const defaultBatchName = "Imported urls"
+
+type ImportUrlsReq struct {
+	Body struct {
+		Urls           string `json:"urls" minLength:"1" doc:"Urls separated by new lines. Range patterns like file[001-120].jpg or img-[a-z].png are expanded. Lines starting with # are skipped"`
+		Name           string `json:"name" required:"false" doc:"Name of the batch"`
+		SavePath       string `json:"savePath" required:"false"`
+		StartDownload  bool   `json:"startDownload"`
+		AddTopOfQueue  bool   `json:"addTopOfQueue"`
+		AllowDuplicate bool   `json:"allowDuplicate" required:"false" doc:"Add urls that are already downloaded. Duplicates are reported as errors by default"`
+		Concurrency    int    `json:"concurrency" required:"false" minimum:"1" maximum:"32" default:"4" doc:"Number of urls checked at the same time"`
+		types.DownloadRequestOptions
+	}
+}
+type ImportUrlsRes struct {
+	Body struct {
+		Batch   *types.DownloadBatch      `json:"batch" doc:"Batch of the created downloads. null when every url fails"`
+		Results []types.BatchImportResult `json:"results"`
+	}
+}
+
+// ImportUrls adds a download for every url of the text as one batch. urls that fail are reported in the results
+func (handler *DownloadHandler) ImportUrls(ctx context.Context, input *ImportUrlsReq) (*ImportUrlsRes, error) {
+	headers, err := input.Body.ToHeaders()
+	if err != nil {
+		return nil, huma.Error400BadRequest(err.Error())
+	}
+	return handler.importUrls(input.Body.Urls, input.Body.Name, headers, input.Body.Proxy, input.Body.SavePath, input.Body.Concurrency, input.Body.StartDownload, input.Body.AddTopOfQueue, input.Body.AllowDuplicate)
+}
+
+type ImportUrlFileData struct {
+	UrlFile multipart.File `form-data:"urlFile" content-type:"text/plain" required:"true"`
+}
+type ImportUrlFileReq struct {
+	RawBody huma.MultipartFormFiles[ImportUrlFileData]
+}
+
+func (input *ImportUrlFileReq) Resolve(ctx huma.Context, prefix *huma.PathBuffer) []error {
+	urlFiles := input.RawBody.Form.File["urlFile"]
+	if len(urlFiles) != 1 {
+		return []error{&huma.ErrorDetail{
+			Location: prefix.String(),
+			Message:  "exactly one url file must be provided",
+			Value:    input,
+		}}
+	}
+	if urlFiles[0].Size > maxUrlFileSize {
+		return []error{&huma.ErrorDetail{
+			Location: prefix.String(),
+			Message:  "url file is too big",
+			Value:    urlFiles[0].Size,
+		}}
+	}
+	return nil
+}
+
+// ImportUrlFile adds a download for every url of the uploaded text file as one batch
+func (handler *DownloadHandler) ImportUrlFile(ctx context.Context, input *ImportUrlFileReq) (*ImportUrlsRes, error) {
+	form := input.RawBody.Form
+	urlFile, err := form.File["urlFile"][0].Open()
+	if err != nil {
+		return nil, err
+	}
+	defer urlFile.Close()
+	text, err := io.ReadAll(urlFile)
+	if err != nil {
+		return nil, err
+	}
+
+	concurrency := 4
+	if value := formValue(form, "concurrency"); value != "" {
+		concurrency, err = strconv.Atoi(value)
+		if err != nil || concurrency < 1 || concurrency > 32 {
+			return nil, huma.Error400BadRequest("concurrency must be between 1 and 32")
+		}
+	}
+	name := formValue(form, "name")
+	if name == "" {
+		name = form.File["urlFile"][0].Filename
+	}
+	return handler.importUrls(string(text), name, nil, formValue(form, "proxy"), formValue(form, "savePath"), concurrency, formValue(form, "startDownload") == "true", formValue(form, "addTopOfQueue") == "true", formValue(form, "allowDuplicate") == "true")
+}
+
+func (handler *DownloadHandler) importUrls(text string, name string, headers types.DownloadHeaders, proxyUrl string, savePath string, concurrency int, startDownload bool, addTopOfQueue bool, allowDuplicate bool) (*ImportUrlsRes, error) {
+	urls, err := direct.ExpandUrlPatterns(text)
+	if err != nil {
+		return nil, huma.Error400BadRequest(err.Error())
+	}
+	if len(urls) == 0 {
+		return nil, huma.Error400BadRequest("no urls to import")
+	}
+	if name == "" {
+		name = defaultBatchName
+	}
+
+	batch, results, err := handler.Engine.ImportUrls(name, "", urls, headers, proxyUrl, savePath, concurrency, startDownload, addTopOfQueue, allowDuplicate)
+	if err != nil {
+		return nil, err
+	}
+	res := &ImportUrlsRes{}
+	res.Body.Batch = batch
+	res.Body.Results = results
+	return res, nil
+}
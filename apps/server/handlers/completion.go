@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"downite/completion"
+	"downite/types"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+type CompletionHandler struct {
+	Runner *completion.ActionRunner
+}
+
+type GetCompletionRulesRes struct {
+	Body []*types.CompletionRule
+}
+
+func (handler *CompletionHandler) GetCompletionRules(ctx context.Context, input *struct{}) (*GetCompletionRulesRes, error) {
+	res := &GetCompletionRulesRes{}
+	res.Body = handler.Runner.GetRules()
+	return res, nil
+}
+
+type AddCompletionRuleReq struct {
+	Body struct {
+		Name      string   `json:"name" required:"false"`
+		Kind      string   `json:"kind" enum:"all,download,torrent" doc:"Kind of the transfers the rule runs for. Move action is only supported for downloads"`
+		Action    string   `json:"action" enum:"move,command,webhook"`
+		Target    string   `json:"target" doc:"Directory for move, executable for command and url for webhook. Executables must be in allowed commands of the settings file. Webhooks are posted the completed transfer as json"`
+		Arguments []string `json:"arguments" required:"false" doc:"Arguments of the command. {path}, {name}, {savePath}, {size}, {kind}, {id} and {url} are replaced with the values of the transfer. They are also given as DOWNITE_ environment variables"`
+	}
+}
+type AddCompletionRuleRes struct {
+	Body *types.CompletionRule
+}
+
+func (handler *CompletionHandler) AddCompletionRule(ctx context.Context, input *AddCompletionRuleReq) (*AddCompletionRuleRes, error) {
+	res := &AddCompletionRuleRes{}
+	rule := &types.CompletionRule{
+		Name:      input.Body.Name,
+		Kind:      input.Body.Kind,
+		Action:    input.Body.Action,
+		Target:    input.Body.Target,
+		Arguments: input.Body.Arguments,
+	}
+	err := handler.Runner.AddRule(rule)
+	if err != nil {
+		return nil, huma.Error400BadRequest(err.Error())
+	}
+	res.Body = rule
+	return res, nil
+}
+
+type CompletionActionReq struct {
+	Body struct {
+		Ids []int `json:"ids"`
+	}
+}
+type CompletionActionRes struct {
+	Body struct{}
+}
+
+func (handler *CompletionHandler) DeleteCompletionRules(ctx context.Context, input *CompletionActionReq) (*CompletionActionRes, error) {
+	res := &CompletionActionRes{}
+	for _, id := range input.Body.Ids {
+		err := handler.Runner.DeleteRule(id)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+type GetCompletionResultsReq struct {
+	DownloadId      int    `query:"downloadId"`
+	TorrentInfohash string `query:"torrentInfohash" maxLength:"40"`
+}
+type GetCompletionResultsRes struct {
+	Body []*types.CompletionResult
+}
+
+func (input *GetCompletionResultsReq) Resolve(ctx huma.Context, prefix *huma.PathBuffer) []error {
+	if (input.DownloadId == 0) == (input.TorrentInfohash == "") {
+		return []error{&huma.ErrorDetail{
+			Location: prefix.With("query"),
+			Message:  "either downloadId or torrentInfohash must be given",
+		}}
+	}
+	return nil
+}
+
+func (handler *CompletionHandler) GetCompletionResults(ctx context.Context, input *GetCompletionResultsReq) (*GetCompletionResultsRes, error) {
+	res := &GetCompletionResultsRes{}
+	results, err := handler.Runner.GetResults(input.DownloadId, input.TorrentInfohash)
+	if err != nil {
+		return nil, err
+	}
+	res.Body = results
+	return res, nil
+}
@@ -3,10 +3,12 @@ package handlers_test
 import (
 	"downite/api"
 	"downite/db"
+	"downite/download/protocol/ftp/ftptest"
 	"downite/handlers"
 	"downite/types"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/danielgtaylor/huma/v2/humatest"
@@ -30,7 +32,7 @@ func initDownloadTest(t *testing.T) humatest.TestAPI {
 	if err != nil {
 		t.Errorf("Cannot connect to db : %s", err)
 	}
-	engine, err := api.InitDownloadEngine(db)
+	engine, err := api.InitDownloadEngine(db, nil)
 	if err != nil {
 		t.Errorf("Cannot initilize download engine : %s", err)
 	}
@@ -91,3 +93,50 @@ func TestDownloadPause(t *testing.T) {
 		t.Errorf("Expected code 200 got code %d and response %v", res.Code, res.Body)
 	}
 }
+
+func TestDownloadHidesFtpCredentials(t *testing.T) {
+	testApi := initDownloadTest(t)
+	server := ftptest.NewServer(t, "/file.bin", []byte("0123456789"))
+	mirrorServer := ftptest.NewServer(t, "/file.bin", []byte("0123456789"))
+
+	res := testApi.Post("/download", map[string]any{
+		"name":                        "",
+		"url":                         server.Url(),
+		"mirrors":                     []string{mirrorServer.Url()},
+		"category":                    "",
+		"savePath":                    t.TempDir(),
+		"isIncompleteSavePathEnabled": false,
+		"incompleteSavePath":          "",
+		"contentLayout":               "Original",
+		"tags":                        []string{},
+		"startDownload":               false,
+		"addTopOfQueue":               false,
+		"overwrite":                   false,
+	})
+	if res.Code != 200 {
+		t.Fatalf("Expected code 200 got code %d and response %v", res.Code, res.Body)
+	}
+	if strings.Contains(res.Body.String(), "pass") {
+		t.Errorf("response of the added download has the password : %s", res.Body)
+	}
+	downloadRes := types.Download{}
+	err := json.Unmarshal(res.Body.Bytes(), &downloadRes)
+	if err != nil {
+		t.Fatalf("cannot unmarshal response : %s", err)
+	}
+
+	for _, path := range []string{fmt.Sprintf("/download/%d", downloadRes.Id), "/download"} {
+		res = testApi.Get(path)
+		if res.Code != 200 {
+			t.Fatalf("Expected code 200 got code %d and response %v", res.Code, res.Body)
+		}
+		if strings.Contains(res.Body.String(), "pass") {
+			t.Errorf("response of %s has the password : %s", path, res.Body)
+		}
+	}
+
+	res = testApi.Post("/download/delete", map[string]any{"ids": []int{downloadRes.Id}})
+	if res.Code != 200 {
+		t.Errorf("Expected code 200 got code %d and response %v", res.Code, res.Body)
+	}
+}
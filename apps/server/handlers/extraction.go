@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"context"
+	"downite/extract"
+	"downite/types"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+type ExtractionHandler struct {
+	Extractor *extract.Extractor
+}
+
+type GetExtractionsReq struct {
+	DownloadId      int    `query:"downloadId"`
+	TorrentInfohash string `query:"torrentInfohash" maxLength:"40"`
+}
+type GetExtractionsRes struct {
+	Body []*types.Extraction
+}
+
+func (input *GetExtractionsReq) Resolve(ctx huma.Context, prefix *huma.PathBuffer) []error {
+	if (input.DownloadId == 0) == (input.TorrentInfohash == "") {
+		return []error{&huma.ErrorDetail{
+			Location: prefix.With("query"),
+			Message:  "either downloadId or torrentInfohash must be given",
+		}}
+	}
+	return nil
+}
+
+func (handler *ExtractionHandler) GetExtractions(ctx context.Context, input *GetExtractionsReq) (*GetExtractionsRes, error) {
+	res := &GetExtractionsRes{}
+	extractions, err := handler.Extractor.GetExtractions(input.DownloadId, input.TorrentInfohash)
+	if err != nil {
+		return nil, err
+	}
+	res.Body = extractions
+	return res, nil
+}
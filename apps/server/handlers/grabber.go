@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"downite/download/grabber"
+	"downite/download/protocol/direct"
+	"downite/types"
+	"regexp"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+// grabbing a page with its links gives up after this duration
+const grabTimeout = 2 * time.Minute
+
+type GrabberHandler struct {
+	Engine *direct.DirectDownloadEngine
+}
+
+type GrabLinksReq struct {
+	Body struct {
+		Url         string   `json:"url" minLength:"1" doc:"http or https url of the page"`
+		Extensions  []string `json:"extensions" required:"false" doc:"Extensions of the links to keep like zip or .mp4"`
+		MimeTypes   []string `json:"mimeTypes" required:"false" doc:"Mime types of the links to keep like video/mp4. Types ending with / match every subtype like video/"`
+		Pattern     string   `json:"pattern" required:"false" doc:"Regular expression the urls of the links must match"`
+		Concurrency int      `json:"concurrency" required:"false" minimum:"1" maximum:"32" default:"4" doc:"Number of links checked at the same time"`
+		types.DownloadRequestOptions
+	}
+}
+type GrabLinksRes struct {
+	Body []*grabber.Link
+}
+
+func (input *GrabLinksReq) Resolve(ctx huma.Context, prefix *huma.PathBuffer) []error {
+	if input.Body.Pattern == "" {
+		return nil
+	}
+	_, err := regexp.Compile(input.Body.Pattern)
+	if err != nil {
+		return []error{&huma.ErrorDetail{
+			Location: prefix.With("body.pattern"),
+			Message:  err.Error(),
+			Value:    input.Body.Pattern,
+		}}
+	}
+	return nil
+}
+
+// GrabLinks lists the files linked from the page with their sizes so that the ones to download can be chosen
+func (handler *GrabberHandler) GrabLinks(ctx context.Context, input *GrabLinksReq) (*GrabLinksRes, error) {
+	headers, err := input.Body.ToHeaders()
+	if err != nil {
+		return nil, huma.Error400BadRequest(err.Error())
+	}
+	httpClient, err := handler.Engine.GetHttpClient(input.Body.Proxy)
+	if err != nil {
+		return nil, huma.Error400BadRequest(err.Error())
+	}
+	filter := &grabber.Filter{
+		Extensions: input.Body.Extensions,
+		MimeTypes:  input.Body.MimeTypes,
+	}
+	if input.Body.Pattern != "" {
+		filter.Pattern = regexp.MustCompile(input.Body.Pattern)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, grabTimeout)
+	defer cancel()
+	links, err := grabber.Grab(ctx, httpClient, input.Body.Url, headers, filter, input.Body.Concurrency)
+	if err != nil {
+		return nil, huma.Error400BadRequest(err.Error())
+	}
+	res := &GrabLinksRes{}
+	res.Body = links
+	return res, nil
+}
+
+type DownloadGrabbedLinksReq struct {
+	Body struct {
+		PageUrl        string   `json:"pageUrl" minLength:"1" doc:"Url of the page the links are grabbed from. It is sent as Referer"`
+		Urls           []string `json:"urls" minItems:"1" doc:"Urls of the chosen links"`
+		Name           string   `json:"name" required:"false" doc:"Name of the batch. Default is the page url"`
+		SavePath       string   `json:"savePath" required:"false"`
+		StartDownload  bool     `json:"startDownload"`
+		AddTopOfQueue  bool     `json:"addTopOfQueue"`
+		AllowDuplicate bool     `json:"allowDuplicate" required:"false" doc:"Add links that are already downloaded. Duplicates are reported as errors by default"`
+		Concurrency    int      `json:"concurrency" required:"false" minimum:"1" maximum:"32" default:"4" doc:"Number of links checked at the same time"`
+		types.DownloadRequestOptions
+	}
+}
+
+// DownloadGrabbedLinks adds the chosen links of a page as a batch of downloads
+func (handler *GrabberHandler) DownloadGrabbedLinks(ctx context.Context, input *DownloadGrabbedLinksReq) (*ImportUrlsRes, error) {
+	headers, err := input.Body.ToHeaders()
+	if err != nil {
+		return nil, huma.Error400BadRequest(err.Error())
+	}
+	if headers == nil {
+		headers = types.DownloadHeaders{}
+	}
+	// files of pages are often only served to visitors of the page
+	if headers["Referer"] == "" {
+		headers["Referer"] = input.Body.PageUrl
+	}
+	name := input.Body.Name
+	if name == "" {
+		name = input.Body.PageUrl
+	}
+
+	batch, results, err := handler.Engine.ImportUrls(name, input.Body.PageUrl, input.Body.Urls, headers, input.Body.Proxy, input.Body.SavePath, input.Body.Concurrency, input.Body.StartDownload, input.Body.AddTopOfQueue, input.Body.AllowDuplicate)
+	if err != nil {
+		return nil, err
+	}
+	res := &ImportUrlsRes{}
+	res.Body.Batch = batch
+	res.Body.Results = results
+	return res, nil
+}
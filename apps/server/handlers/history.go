@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"context"
+	"downite/history"
+	"downite/types"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+type HistoryHandler struct {
+	History *history.History
+}
+
+type GetSpeedHistoryReq struct {
+	DownloadId      int    `query:"downloadId" doc:"History of a download. History of all transfers is returned when no download or torrent is given"`
+	TorrentInfohash string `query:"torrentInfohash" maxLength:"40"`
+	Resolution      string `query:"resolution" enum:"second,minute" default:"second" doc:"second is the last hour, minute is the last day"`
+}
+type GetSpeedHistoryRes struct {
+	Body []types.SpeedSample
+}
+
+func (input *GetSpeedHistoryReq) Resolve(ctx huma.Context, prefix *huma.PathBuffer) []error {
+	if input.DownloadId != 0 && input.TorrentInfohash != "" {
+		return []error{&huma.ErrorDetail{
+			Location: prefix.With("query"),
+			Message:  "downloadId and torrentInfohash can't be given together",
+		}}
+	}
+	return nil
+}
+
+// GetSpeedHistory returns the speeds in KB/s for charts. transfers have no samples while they are idle
+func (handler *HistoryHandler) GetSpeedHistory(ctx context.Context, input *GetSpeedHistoryReq) (*GetSpeedHistoryRes, error) {
+	res := &GetSpeedHistoryRes{}
+	resolution := types.HistoryResolutionSecond
+	if input.Resolution == types.HistoryResolutionMinute.String() {
+		resolution = types.HistoryResolutionMinute
+	}
+	res.Body = handler.History.GetSpeedHistory(input.DownloadId, input.TorrentInfohash, resolution)
+	return res, nil
+}
+
+type GetDailyTotalsReq struct {
+	Days int `query:"days" minimum:"1" maximum:"3650" default:"30" doc:"Number of days including today"`
+}
+type GetDailyTotalsRes struct {
+	Body []*types.DailyTransferTotal
+}
+
+func (handler *HistoryHandler) GetDailyTotals(ctx context.Context, input *GetDailyTotalsReq) (*GetDailyTotalsRes, error) {
+	res := &GetDailyTotalsRes{}
+	totals, err := handler.History.GetDailyTotals(input.Days)
+	if err != nil {
+		return nil, err
+	}
+	res.Body = totals
+	return res, nil
+}
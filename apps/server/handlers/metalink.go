@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"context"
+	"downite/download/metalink"
+	"downite/download/protocol/direct"
+	"downite/types"
+	"fmt"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+type ImportMetalinkData struct {
+	MetalinkFile multipart.File `form-data:"metalinkFile" content-type:"application/metalink4+xml" required:"true"`
+}
+type ImportMetalinkReq struct {
+	RawBody huma.MultipartFormFiles[ImportMetalinkData]
+}
+
+// ImportMetalinkResult is either a download or a torrent created for a file of the metalink
+type ImportMetalinkResult struct {
+	Name            string `json:"name"`
+	DownloadId      int    `json:"downloadId"`
+	TorrentInfohash string `json:"torrentInfohash"`
+	Error           string `json:"error"`
+}
+type ImportMetalinkRes struct {
+	Body []ImportMetalinkResult
+}
+
+func (input *ImportMetalinkReq) Resolve(ctx huma.Context, prefix *huma.PathBuffer) []error {
+	metalinkFiles := input.RawBody.Form.File["metalinkFile"]
+	if len(metalinkFiles) != 1 {
+		return []error{&huma.ErrorDetail{
+			Location: prefix.String(),
+			Message:  "exactly one metalink file must be provided",
+			Value:    input,
+		}}
+	}
+	return nil
+}
+
+// formValue returns the first value of the form field. missing fields are empty
+func formValue(form *multipart.Form, key string) string {
+	if len(form.Value[key]) == 0 {
+		return ""
+	}
+	return form.Value[key][0]
+}
+
+// ImportMetalink creates a download for every file of the metalink. files without usable urls are downloaded from their torrent
+func (handler *DownloadHandler) ImportMetalink(ctx context.Context, input *ImportMetalinkReq) (*ImportMetalinkRes, error) {
+	form := input.RawBody.Form
+	metalinkFile, err := form.File["metalinkFile"][0].Open()
+	if err != nil {
+		return nil, err
+	}
+	defer metalinkFile.Close()
+	parsedMetalink, err := metalink.Parse(metalinkFile)
+	if err != nil {
+		return nil, huma.Error400BadRequest(err.Error())
+	}
+
+	savePath := formValue(form, "savePath")
+	if savePath == "" {
+		savePath = handler.Engine.DownloadClientConfig.DownloadPath
+	}
+	startDownload := formValue(form, "startDownload") == "true"
+	addTopOfQueue := formValue(form, "addTopOfQueue") == "true"
+
+	res := &ImportMetalinkRes{}
+	res.Body = make([]ImportMetalinkResult, 0, len(parsedMetalink.Files))
+	for _, file := range parsedMetalink.Files {
+		result := ImportMetalinkResult{Name: file.Name}
+
+		downloadId, err := handler.importMetalinkFile(file, savePath, startDownload, addTopOfQueue)
+		if err == nil {
+			result.DownloadId = downloadId
+		} else if len(file.TorrentUrls) > 0 && handler.TorrentEngine != nil {
+			fmt.Printf("Downloading %s from its torrent : %s \n", file.Name, err)
+			result.TorrentInfohash, err = handler.importMetalinkTorrent(file, savePath, startDownload, addTopOfQueue)
+		}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		res.Body = append(res.Body, result)
+	}
+	return res, nil
+}
+
+// importMetalinkFile downloads the file from its urls. urls are tried in order of their priorities
+func (handler *DownloadHandler) importMetalinkFile(file *metalink.File, savePath string, startDownload bool, addTopOfQueue bool) (int, error) {
+	urls := file.DirectUrls()
+	if len(urls) == 0 {
+		return 0, fmt.Errorf("file has no http or ftp urls")
+	}
+
+	// names of metalink files may contain directories
+	err := os.MkdirAll(filepath.Dir(filepath.Join(savePath, filepath.FromSlash(file.Name))), os.ModePerm)
+	if err != nil {
+		return 0, err
+	}
+	download, err := handler.Engine.DownloadFromUrl(urls[0], direct.DownloadOptions{
+		Name:           filepath.FromSlash(file.Name),
+		MirrorUrls:     urls[1:],
+		SavePath:       savePath,
+		AddTopOfQueue:  addTopOfQueue,
+		AllowDuplicate: true,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	err = handler.applyMetalinkHashes(download, file)
+	if err != nil {
+		removeErr := handler.Engine.RemoveDownload(download.Id)
+		if removeErr != nil {
+			fmt.Printf("Error while removing download : %s \n", removeErr)
+		}
+		return 0, err
+	}
+
+	if startDownload {
+		err = handler.Engine.QueueDownload(download.Id)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return download.Id, nil
+}
+
+// applyMetalinkHashes checks the size of the file and sets its checksum and piece hashes
+func (handler *DownloadHandler) applyMetalinkHashes(download *types.Download, file *metalink.File) error {
+	downloadId := download.Id
+	// size of the url can only be compared when the server sends it
+	if file.Size != 0 && !download.IsSizeUnknown && file.Size != download.TotalSize {
+		return fmt.Errorf("metalink size %d does not match size %d of the url", file.Size, download.TotalSize)
+	}
+	algorithm, checksum := file.Checksum()
+	if algorithm != "" {
+		err := handler.Engine.SetDownloadChecksum(downloadId, algorithm, checksum)
+		if err != nil {
+			return err
+		}
+	}
+	if len(file.PieceHashes) > 0 {
+		// the file can still be verified with its checksum
+		err := handler.Engine.SetDownloadPieceHashes(downloadId, file.PieceHashAlgorithm, file.PieceLength, file.PieceHashes)
+		if err != nil {
+			fmt.Printf("Ignoring piece hashes of %s : %s \n", file.Name, err)
+		}
+	}
+	return nil
+}
+
+// importMetalinkTorrent downloads the file with the first torrent of it that can be added
+func (handler *DownloadHandler) importMetalinkTorrent(file *metalink.File, savePath string, startDownload bool, addTopOfQueue bool) (string, error) {
+	var err error
+	for _, torrentUrl := range file.TorrentUrls {
+		torrent, torrentErr := handler.TorrentEngine.DownloadTorrentFromUrl(torrentUrl, savePath, startDownload, addTopOfQueue)
+		if torrentErr == nil {
+			return torrent.Infohash, nil
+		}
+		err = torrentErr
+	}
+	return "", err
+}
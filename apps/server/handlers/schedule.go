@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"context"
+	"downite/scheduler"
+	"downite/types"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+type ScheduleHandler struct {
+	Scheduler *scheduler.Scheduler
+}
+
+type GetScheduledTasksRes struct {
+	Body []*types.ScheduledTask
+}
+
+func (handler *ScheduleHandler) GetScheduledTasks(ctx context.Context, input *struct{}) (*GetScheduledTasksRes, error) {
+	res := &GetScheduledTasksRes{}
+	res.Body = handler.Scheduler.GetTasks()
+	return res, nil
+}
+
+type AddScheduledTaskReq struct {
+	Body struct {
+		RunAt           time.Time `json:"runAt" doc:"Time to run the task. Tasks in the past run right away"`
+		Action          string    `json:"action" enum:"start,pause"`
+		DownloadId      int       `json:"downloadId" required:"false"`
+		TorrentInfohash string    `json:"torrentInfohash" required:"false"`
+	}
+}
+type AddScheduledTaskRes struct {
+	Body *types.ScheduledTask
+}
+
+func (input *AddScheduledTaskReq) Resolve(ctx huma.Context, prefix *huma.PathBuffer) []error {
+	if (input.Body.DownloadId == 0) == (input.Body.TorrentInfohash == "") {
+		return []error{&huma.ErrorDetail{
+			Location: prefix.With("body"),
+			Message:  "either downloadId or torrentInfohash must be given",
+			Value:    input.Body,
+		}}
+	}
+	return nil
+}
+
+func (handler *ScheduleHandler) AddScheduledTask(ctx context.Context, input *AddScheduledTaskReq) (*AddScheduledTaskRes, error) {
+	res := &AddScheduledTaskRes{}
+	task := &types.ScheduledTask{
+		RunAt:           input.Body.RunAt,
+		Action:          input.Body.Action,
+		DownloadId:      input.Body.DownloadId,
+		TorrentInfohash: input.Body.TorrentInfohash,
+	}
+	err := handler.Scheduler.AddTask(task)
+	if err != nil {
+		return nil, huma.Error400BadRequest(err.Error())
+	}
+	res.Body = task
+	return res, nil
+}
+
+type ScheduleActionReq struct {
+	Body struct {
+		Ids []int `json:"ids"`
+	}
+}
+type ScheduleActionRes struct {
+	Body struct{}
+}
+
+func (handler *ScheduleHandler) DeleteScheduledTasks(ctx context.Context, input *ScheduleActionReq) (*ScheduleActionRes, error) {
+	res := &ScheduleActionRes{}
+	for _, id := range input.Body.Ids {
+		err := handler.Scheduler.DeleteTask(id)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+type GetScheduleWindowsRes struct {
+	Body []*types.ScheduleWindow
+}
+
+func (handler *ScheduleHandler) GetScheduleWindows(ctx context.Context, input *struct{}) (*GetScheduleWindowsRes, error) {
+	res := &GetScheduleWindowsRes{}
+	res.Body = handler.Scheduler.GetWindows()
+	return res, nil
+}
+
+type AddScheduleWindowReq struct {
+	Body struct {
+		Weekday   int    `json:"weekday" minimum:"0" maximum:"6" doc:"Day of the week in local time. 0 is sunday"`
+		StartTime string `json:"startTime" pattern:"^([01][0-9]|2[0-3]):[0-5][0-9]$" doc:"Time like 23:00"`
+		EndTime   string `json:"endTime" pattern:"^([01][0-9]|2[0-3]):[0-5][0-9]$" doc:"Time like 07:00. Window ends on the next day when it is not after start time"`
+	}
+}
+type AddScheduleWindowRes struct {
+	Body *types.ScheduleWindow
+}
+
+func (handler *ScheduleHandler) AddScheduleWindow(ctx context.Context, input *AddScheduleWindowReq) (*AddScheduleWindowRes, error) {
+	res := &AddScheduleWindowRes{}
+	window := &types.ScheduleWindow{
+		Weekday:   input.Body.Weekday,
+		StartTime: input.Body.StartTime,
+		EndTime:   input.Body.EndTime,
+	}
+	err := handler.Scheduler.AddWindow(window)
+	if err != nil {
+		return nil, huma.Error400BadRequest(err.Error())
+	}
+	res.Body = window
+	return res, nil
+}
+
+func (handler *ScheduleHandler) DeleteScheduleWindows(ctx context.Context, input *ScheduleActionReq) (*ScheduleActionRes, error) {
+	res := &ScheduleActionRes{}
+	for _, id := range input.Body.Ids {
+		err := handler.Scheduler.DeleteWindow(id)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
@@ -2,11 +2,20 @@ package handlers
 
 import (
 	"context"
+	"downite/download/protocol/direct"
+	"downite/extract"
 	"downite/settings"
+	"downite/types"
+	"fmt"
+	"path/filepath"
+
+	"github.com/danielgtaylor/huma/v2"
 )
 
 type SettingsHandler struct {
 	SettingsSystem *settings.DowniteSettingsSystem
+	DownloadEngine *direct.DirectDownloadEngine
+	Extractor      *extract.Extractor
 }
 
 type AddSavePathReq struct {
@@ -34,3 +43,215 @@ func (handler *SettingsHandler) GetSavePaths(ctx context.Context, input *struct{
 	res.Body = handler.SettingsSystem.Settings.SavePaths
 	return res, nil
 }
+
+type MaxConcurrentDownloadsData struct {
+	MaxConcurrentDownloads int `json:"maxConcurrentDownloads" minimum:"0" doc:"Maximum number of downloads running at the same time. 0 means unlimited"`
+}
+type GetMaxConcurrentDownloadsRes struct {
+	Body MaxConcurrentDownloadsData
+}
+
+func (handler *SettingsHandler) GetMaxConcurrentDownloads(ctx context.Context, input *struct{}) (*GetMaxConcurrentDownloadsRes, error) {
+	res := &GetMaxConcurrentDownloadsRes{}
+	res.Body.MaxConcurrentDownloads = handler.SettingsSystem.Settings.MaxConcurrentDownloads
+	return res, nil
+}
+
+type SetMaxConcurrentDownloadsReq struct {
+	Body MaxConcurrentDownloadsData
+}
+type SetMaxConcurrentDownloadsRes struct {
+	Body bool
+}
+
+func (handler *SettingsHandler) SetMaxConcurrentDownloads(ctx context.Context, input *SetMaxConcurrentDownloadsReq) (*SetMaxConcurrentDownloadsRes, error) {
+	res := &SetMaxConcurrentDownloadsRes{}
+	err := handler.SettingsSystem.SetMaxConcurrentDownloads(input.Body.MaxConcurrentDownloads)
+	if err != nil {
+		return nil, err
+	}
+	handler.DownloadEngine.SetMaxConcurrentDownloads(input.Body.MaxConcurrentDownloads)
+	res.Body = true
+	return res, nil
+}
+
+type GetProxyRes struct {
+	Body types.ProxySettings
+}
+
+func (handler *SettingsHandler) GetProxy(ctx context.Context, input *struct{}) (*GetProxyRes, error) {
+	res := &GetProxyRes{}
+	res.Body = handler.SettingsSystem.Settings.Proxy
+	return res, nil
+}
+
+type SetProxyReq struct {
+	Body types.ProxySettings
+}
+type SetProxyRes struct {
+	Body bool
+}
+
+// SetProxy applies the proxy to direct downloads right away. torrents use it after restart
+func (handler *SettingsHandler) SetProxy(ctx context.Context, input *SetProxyReq) (*SetProxyRes, error) {
+	res := &SetProxyRes{}
+	proxyUrl, err := input.Body.Url()
+	if err != nil {
+		return nil, huma.Error400BadRequest(err.Error())
+	}
+	err = handler.DownloadEngine.SetProxy(proxyUrl)
+	if err != nil {
+		return nil, huma.Error400BadRequest(err.Error())
+	}
+	err = handler.SettingsSystem.SetProxy(input.Body)
+	if err != nil {
+		return nil, err
+	}
+	res.Body = true
+	return res, nil
+}
+
+type GetExtractionSettingsRes struct {
+	Body types.ExtractionSettings
+}
+
+func (handler *SettingsHandler) GetExtractionSettings(ctx context.Context, input *struct{}) (*GetExtractionSettingsRes, error) {
+	res := &GetExtractionSettingsRes{}
+	res.Body = handler.SettingsSystem.Settings.Extraction
+	return res, nil
+}
+
+type SetExtractionSettingsReq struct {
+	Body types.ExtractionSettings
+}
+type SetExtractionSettingsRes struct {
+	Body bool
+}
+
+// SetExtractionSettings applies to the transfers completed after it
+func (handler *SettingsHandler) SetExtractionSettings(ctx context.Context, input *SetExtractionSettingsReq) (*SetExtractionSettingsRes, error) {
+	res := &SetExtractionSettingsRes{}
+	if input.Body.ExtractPath != "" && !filepath.IsAbs(input.Body.ExtractPath) {
+		return nil, huma.Error400BadRequest("extract path must be an absolute path")
+	}
+	err := handler.SettingsSystem.SetExtraction(input.Body)
+	if err != nil {
+		return nil, err
+	}
+	handler.Extractor.SetSettings(input.Body)
+	res.Body = true
+	return res, nil
+}
+
+type GetHostSettingsRes struct {
+	Body types.HostSettings
+}
+
+func (handler *SettingsHandler) GetHostSettings(ctx context.Context, input *struct{}) (*GetHostSettingsRes, error) {
+	res := &GetHostSettingsRes{}
+	res.Body = handler.DownloadEngine.GetHostSettings()
+	return res, nil
+}
+
+type SetHostSettingsReq struct {
+	Body types.HostSettings
+}
+type SetHostSettingsRes struct {
+	Body bool
+}
+
+func (input *SetHostSettingsReq) Resolve(ctx huma.Context, prefix *huma.PathBuffer) []error {
+	errs := make([]error, 0)
+	hosts := make(map[string]bool)
+	for i, override := range input.Body.Overrides {
+		host := direct.NormalizeHost(override.Host)
+		message := ""
+		if host == "" {
+			message = "host is not a valid host name"
+		} else if hosts[host] {
+			message = "host is overridden more than once"
+		}
+		if message != "" {
+			errs = append(errs, &huma.ErrorDetail{
+				Location: prefix.With(fmt.Sprintf("body.overrides[%d].host", i)),
+				Message:  message,
+				Value:    override.Host,
+			})
+		}
+		hosts[host] = true
+	}
+	return errs
+}
+
+// SetHostSettings applies to new connections right away. part counts apply to the downloads added after it
+func (handler *SettingsHandler) SetHostSettings(ctx context.Context, input *SetHostSettingsReq) (*SetHostSettingsRes, error) {
+	res := &SetHostSettingsRes{}
+	handler.DownloadEngine.SetHostSettings(input.Body)
+	// hosts are saved like the engine normalized them
+	err := handler.SettingsSystem.SetHosts(handler.DownloadEngine.GetHostSettings())
+	if err != nil {
+		return nil, err
+	}
+	res.Body = true
+	return res, nil
+}
+
+type GetRetrySettingsRes struct {
+	Body types.RetrySettings
+}
+
+func (handler *SettingsHandler) GetRetrySettings(ctx context.Context, input *struct{}) (*GetRetrySettingsRes, error) {
+	res := &GetRetrySettingsRes{}
+	res.Body = handler.DownloadEngine.GetRetrySettings()
+	return res, nil
+}
+
+type SetRetrySettingsReq struct {
+	Body types.RetrySettings
+}
+type SetRetrySettingsRes struct {
+	Body bool
+}
+
+// SetRetrySettings applies to the parts started after it
+func (handler *SettingsHandler) SetRetrySettings(ctx context.Context, input *SetRetrySettingsReq) (*SetRetrySettingsRes, error) {
+	res := &SetRetrySettingsRes{}
+	err := handler.SettingsSystem.SetRetry(input.Body)
+	if err != nil {
+		return nil, err
+	}
+	handler.DownloadEngine.SetRetrySettings(input.Body)
+	res.Body = true
+	return res, nil
+}
+
+type RestartChangedDownloadsData struct {
+	RestartChangedDownloads bool `json:"restartChangedDownloads" doc:"Start downloads whose remote file changed again from the beginning. Otherwise they fail"`
+}
+type GetRestartChangedDownloadsRes struct {
+	Body RestartChangedDownloadsData
+}
+
+func (handler *SettingsHandler) GetRestartChangedDownloads(ctx context.Context, input *struct{}) (*GetRestartChangedDownloadsRes, error) {
+	res := &GetRestartChangedDownloadsRes{}
+	res.Body.RestartChangedDownloads = handler.DownloadEngine.GetRestartChangedDownloads()
+	return res, nil
+}
+
+type SetRestartChangedDownloadsReq struct {
+	Body RestartChangedDownloadsData
+}
+type SetRestartChangedDownloadsRes struct {
+	Body bool
+}
+
+func (handler *SettingsHandler) SetRestartChangedDownloads(ctx context.Context, input *SetRestartChangedDownloadsReq) (*SetRestartChangedDownloadsRes, error) {
+	res := &SetRestartChangedDownloadsRes{}
+	err := handler.SettingsSystem.SetRestartChangedDownloads(input.Body.RestartChangedDownloads)
+	if err != nil {
+		return nil, err
+	}
+	handler.DownloadEngine.SetRestartChangedDownloads(input.Body.RestartChangedDownloads)
+	res.Body = true
+	return res, nil
+}
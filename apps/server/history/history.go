@@ -0,0 +1,305 @@
+package history
+
+import (
+	"downite/db"
+	"downite/download/protocol/direct"
+	"downite/download/protocol/torr"
+	"downite/types"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// speeds are sampled in this interval
+	sampleInterval = time.Second
+	// last hour is kept at 1s resolution
+	secondSampleCount = 3600
+	// last day is kept at 1m resolution
+	minuteSampleCount = 1440
+	// daily totals are saved to db in this interval
+	flushInterval = time.Minute
+)
+
+// series is the speed history of a transfer or of all transfers
+type series struct {
+	seconds []types.SpeedSample
+	minutes []types.SpeedSample
+	// samples of the current minute are summed until the minute is over
+	minuteStart   time.Time
+	minuteSamples types.SpeedSample
+	// transfers are only sampled while they are active
+	isActive bool
+}
+
+// add adds the sample. samples of a minute are averaged when the next minute starts
+func (series *series) add(sample types.SpeedSample) {
+	series.closeMinute(sample.Time)
+	series.minuteStart = sample.Time.Truncate(time.Minute)
+	series.minuteSamples.DownloadSpeed += sample.DownloadSpeed
+	series.minuteSamples.UploadSpeed += sample.UploadSpeed
+	series.seconds = appendSample(series.seconds, sample, secondSampleCount)
+}
+
+// closeMinute adds the average of the current minute when it is over at now
+func (series *series) closeMinute(now time.Time) {
+	if series.minuteStart.IsZero() || now.Truncate(time.Minute).Equal(series.minuteStart) {
+		return
+	}
+	// seconds without samples count as zero speed
+	series.minutes = appendSample(series.minutes, types.SpeedSample{
+		Time:          series.minuteStart,
+		DownloadSpeed: series.minuteSamples.DownloadSpeed / 60,
+		UploadSpeed:   series.minuteSamples.UploadSpeed / 60,
+	}, minuteSampleCount)
+	series.minuteStart = time.Time{}
+	series.minuteSamples = types.SpeedSample{}
+}
+
+func (series *series) samples(resolution types.HistoryResolution) []types.SpeedSample {
+	source := series.seconds
+	if resolution == types.HistoryResolutionMinute {
+		source = series.minutes
+	}
+	samples := make([]types.SpeedSample, len(source))
+	copy(samples, source)
+	return samples
+}
+
+func appendSample(samples []types.SpeedSample, sample types.SpeedSample, maxCount int) []types.SpeedSample {
+	samples = append(samples, sample)
+	if len(samples) > maxCount {
+		samples = samples[len(samples)-maxCount:]
+	}
+	return samples
+}
+
+// History keeps the speed history of transfers in memory and the daily totals in db
+type History struct {
+	db             *db.Database
+	downloadEngine *direct.DirectDownloadEngine
+	torrentEngine  *torr.TorrentEngine
+	global         *series
+	// keys are download:<id> and torrent:<infohash>
+	transfers map[string]*series
+	// totals that are not saved to db yet by day
+	pendingTotals map[string]*dailyBytes
+	// byte counters of the transfers at the last sample
+	lastTransferredBytes map[string]types.TransferredBytes
+	mutexForHistory      sync.Mutex
+	stop                 chan struct{}
+}
+
+type dailyBytes struct {
+	downloaded uint64
+	uploaded   uint64
+}
+
+func CreateHistory(db *db.Database, downloadEngine *direct.DirectDownloadEngine, torrentEngine *torr.TorrentEngine) *History {
+	return &History{
+		db:                   db,
+		downloadEngine:       downloadEngine,
+		torrentEngine:        torrentEngine,
+		global:               &series{},
+		transfers:            make(map[string]*series),
+		pendingTotals:        make(map[string]*dailyBytes),
+		lastTransferredBytes: make(map[string]types.TransferredBytes),
+		stop:                 make(chan struct{}),
+	}
+}
+
+// InitHistory starts sampling the speeds
+func (history *History) InitHistory() {
+	go history.run()
+}
+
+// Stop stops sampling and saves the daily totals
+func (history *History) Stop() {
+	close(history.stop)
+	history.flush()
+}
+
+func (history *History) run() {
+	sampleTicker := time.NewTicker(sampleInterval)
+	defer sampleTicker.Stop()
+	flushTicker := time.NewTicker(flushInterval)
+	defer flushTicker.Stop()
+	for {
+		select {
+		case <-history.stop:
+			return
+		case now := <-sampleTicker.C:
+			history.sample(now)
+		case <-flushTicker.C:
+			history.flush()
+		}
+	}
+}
+
+// sample records the current speeds and byte counters of the engines
+func (history *History) sample(now time.Time) {
+	speeds := make(map[string]types.TorrentSpeedInfo)
+	transferredBytes := make(map[string]types.TransferredBytes)
+	for id, speed := range history.downloadEngine.GetDownloadSpeeds() {
+		speeds[downloadKey(id)] = types.TorrentSpeedInfo{DownloadSpeed: float32(speed)}
+	}
+	for id, downloadedBytes := range history.downloadEngine.GetDownloadedBytes() {
+		transferredBytes[downloadKey(id)] = types.TransferredBytes{DownloadedBytes: downloadedBytes}
+	}
+	if history.torrentEngine != nil {
+		for infohash, speed := range history.torrentEngine.GetTorrentSpeeds() {
+			speeds[torrentKey(infohash)] = speed
+		}
+		for infohash, bytes := range history.torrentEngine.GetTransferredBytes() {
+			transferredBytes[torrentKey(infohash)] = bytes
+		}
+	}
+	history.record(now, speeds, transferredBytes)
+}
+
+// record adds the speeds of the transfers by their keys to the history and the increase of their byte counters to the daily totals
+func (history *History) record(now time.Time, speeds map[string]types.TorrentSpeedInfo, transferredBytes map[string]types.TransferredBytes) {
+	history.mutexForHistory.Lock()
+	defer history.mutexForHistory.Unlock()
+
+	total := types.SpeedSample{Time: now}
+	for key, speed := range speeds {
+		total.DownloadSpeed += speed.DownloadSpeed
+		total.UploadSpeed += speed.UploadSpeed
+
+		transfer, ok := history.transfers[key]
+		if !ok {
+			transfer = &series{}
+			history.transfers[key] = transfer
+		}
+		isActive := speed.DownloadSpeed > 0 || speed.UploadSpeed > 0
+		// the first idle sample is recorded so that charts drop to zero
+		if isActive || transfer.isActive {
+			transfer.add(types.SpeedSample{
+				Time:          now,
+				DownloadSpeed: speed.DownloadSpeed,
+				UploadSpeed:   speed.UploadSpeed,
+			})
+		} else {
+			transfer.closeMinute(now)
+		}
+		transfer.isActive = isActive
+	}
+	// history of removed transfers is dropped
+	for key := range history.transfers {
+		if _, ok := speeds[key]; !ok {
+			delete(history.transfers, key)
+		}
+	}
+	history.global.add(total)
+
+	day := now.Format(time.DateOnly)
+	pending, ok := history.pendingTotals[day]
+	if !ok {
+		pending = &dailyBytes{}
+		history.pendingTotals[day] = pending
+	}
+	for key, bytes := range transferredBytes {
+		// counters seen for the first time count from now. counters that drop like restarted downloads count from their new value
+		lastBytes, ok := history.lastTransferredBytes[key]
+		if !ok {
+			continue
+		}
+		if bytes.DownloadedBytes > lastBytes.DownloadedBytes {
+			pending.downloaded += bytes.DownloadedBytes - lastBytes.DownloadedBytes
+		}
+		if bytes.UploadedBytes > lastBytes.UploadedBytes {
+			pending.uploaded += bytes.UploadedBytes - lastBytes.UploadedBytes
+		}
+	}
+	history.lastTransferredBytes = transferredBytes
+}
+
+// flush saves the pending totals to db
+func (history *History) flush() {
+	history.mutexForHistory.Lock()
+	defer history.mutexForHistory.Unlock()
+
+	today := time.Now().Format(time.DateOnly)
+	for day, pending := range history.pendingTotals {
+		total := &types.DailyTransferTotal{
+			Day:             day,
+			DownloadedBytes: pending.downloaded,
+			UploadedBytes:   pending.uploaded,
+		}
+		if total.DownloadedBytes != 0 || total.UploadedBytes != 0 {
+			err := history.db.AddDailyTransferTotal(total)
+			if err != nil {
+				fmt.Printf("Error while saving daily transfer totals : %s \n", err)
+				continue
+			}
+		}
+		pending.downloaded = 0
+		pending.uploaded = 0
+		if day != today {
+			delete(history.pendingTotals, day)
+		}
+	}
+}
+
+// GetSpeedHistory returns the speed history of a download, a torrent or of all transfers when both are empty
+func (history *History) GetSpeedHistory(downloadId int, torrentInfohash string, resolution types.HistoryResolution) []types.SpeedSample {
+	history.mutexForHistory.Lock()
+	defer history.mutexForHistory.Unlock()
+
+	target := history.global
+	if downloadId != 0 {
+		target = history.transfers[downloadKey(downloadId)]
+	} else if torrentInfohash != "" {
+		target = history.transfers[torrentKey(torrentInfohash)]
+	}
+	if target == nil {
+		return []types.SpeedSample{}
+	}
+	return target.samples(resolution)
+}
+
+// GetDailyTotals returns the totals of the last days including today. days without transfers are not listed
+func (history *History) GetDailyTotals(days int) ([]*types.DailyTransferTotal, error) {
+	now := time.Now()
+	fromDay := now.AddDate(0, 0, 1-days).Format(time.DateOnly)
+	totals, err := history.db.GetDailyTransferTotals(fromDay)
+	if err != nil {
+		return nil, err
+	}
+
+	history.mutexForHistory.Lock()
+	defer history.mutexForHistory.Unlock()
+	for day, pending := range history.pendingTotals {
+		if day < fromDay {
+			continue
+		}
+		var total *types.DailyTransferTotal
+		for _, savedTotal := range totals {
+			if savedTotal.Day == day {
+				total = savedTotal
+				break
+			}
+		}
+		if total == nil {
+			total = &types.DailyTransferTotal{Day: day}
+			totals = append(totals, total)
+		}
+		total.DownloadedBytes += pending.downloaded
+		total.UploadedBytes += pending.uploaded
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		return totals[i].Day < totals[j].Day
+	})
+	return totals, nil
+}
+
+func downloadKey(id int) string {
+	return "download:" + strconv.Itoa(id)
+}
+
+func torrentKey(infohash string) string {
+	return "torrent:" + infohash
+}
@@ -0,0 +1,73 @@
+package history
+
+import (
+	"downite/types"
+	"testing"
+	"time"
+)
+
+func TestRecord(t *testing.T) {
+	history := CreateHistory(nil, nil, nil)
+	start := time.Date(2024, 1, 31, 10, 0, 0, 0, time.Local)
+
+	// download 1 is active for 30 seconds of the first minute, download 2 is idle
+	for i := 0; i < 90; i++ {
+		var speed float32
+		if i < 30 {
+			speed = 120
+		}
+		// download 2 is restarted at 60 seconds
+		restartedBytes := uint64(5000)
+		if i >= 60 {
+			restartedBytes = uint64(i-60) * 100
+		}
+		history.record(start.Add(time.Duration(i)*time.Second), map[string]types.TorrentSpeedInfo{
+			downloadKey(1):    {DownloadSpeed: speed},
+			downloadKey(2):    {},
+			torrentKey("abc"): {DownloadSpeed: 10, UploadSpeed: 5},
+		}, map[string]types.TransferredBytes{
+			downloadKey(1):    {DownloadedBytes: 1000 + uint64(min(i, 30))*120*1024},
+			downloadKey(2):    {DownloadedBytes: restartedBytes},
+			torrentKey("abc"): {DownloadedBytes: uint64(i) * 10 * 1024, UploadedBytes: uint64(i) * 5 * 1024},
+		})
+	}
+
+	// the first idle sample is kept so that charts drop to zero
+	seconds := history.GetSpeedHistory(1, "", types.HistoryResolutionSecond)
+	if len(seconds) != 31 || seconds[30].DownloadSpeed != 0 {
+		t.Errorf("expected 31 samples of download 1, got %d", len(seconds))
+	}
+	minutes := history.GetSpeedHistory(1, "", types.HistoryResolutionMinute)
+	if len(minutes) != 1 || minutes[0].DownloadSpeed != 60 || !minutes[0].Time.Equal(start) {
+		t.Errorf("expected average of the first minute to be 60, got %v", minutes)
+	}
+	if samples := history.GetSpeedHistory(2, "", types.HistoryResolutionSecond); len(samples) != 0 {
+		t.Errorf("idle downloads must not have samples, got %d", len(samples))
+	}
+
+	global := history.GetSpeedHistory(0, "", types.HistoryResolutionSecond)
+	if len(global) != 90 || global[0].DownloadSpeed != 130 || global[0].UploadSpeed != 5 {
+		t.Errorf("unexpected global history %d %v", len(global), global[0])
+	}
+	// bytes before the first sample are not counted
+	pending := history.pendingTotals[start.Format(time.DateOnly)]
+	if pending.downloaded != 30*120*1024+29*100+89*10*1024 || pending.uploaded != 89*5*1024 {
+		t.Errorf("unexpected daily totals %v", pending)
+	}
+
+	// history of removed transfers is dropped
+	history.record(start.Add(90*time.Second), map[string]types.TorrentSpeedInfo{}, map[string]types.TransferredBytes{})
+	if samples := history.GetSpeedHistory(0, "abc", types.HistoryResolutionSecond); len(samples) != 0 {
+		t.Errorf("expected no history for removed torrent, got %d", len(samples))
+	}
+}
+
+func TestAppendSample(t *testing.T) {
+	samples := []types.SpeedSample{}
+	for i := 0; i < 10; i++ {
+		samples = appendSample(samples, types.SpeedSample{DownloadSpeed: float32(i)}, 4)
+	}
+	if len(samples) != 4 || samples[0].DownloadSpeed != 6 || samples[3].DownloadSpeed != 9 {
+		t.Errorf("expected last 4 samples, got %v", samples)
+	}
+}
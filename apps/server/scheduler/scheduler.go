@@ -0,0 +1,297 @@
+package scheduler
+
+import (
+	"downite/db"
+	"downite/download/protocol/direct"
+	"downite/download/protocol/torr"
+	"downite/types"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// tasks and windows are checked in this interval
+const checkInterval = 5 * time.Second
+
+// Scheduler runs scheduled tasks on time and stops the download queue outside of its time windows
+type Scheduler struct {
+	db               *db.Database
+	downloadEngine   *direct.DirectDownloadEngine
+	torrentEngine    *torr.TorrentEngine
+	tasks            []*types.ScheduledTask
+	windows          []*types.ScheduleWindow
+	mutexForSchedule sync.Mutex
+	stop             chan struct{}
+	// returns the current time. tests replace it to move the clock
+	now func() time.Time
+}
+
+func CreateScheduler(db *db.Database, downloadEngine *direct.DirectDownloadEngine, torrentEngine *torr.TorrentEngine) *Scheduler {
+	return &Scheduler{
+		db:             db,
+		downloadEngine: downloadEngine,
+		torrentEngine:  torrentEngine,
+		stop:           make(chan struct{}),
+		now:            time.Now,
+	}
+}
+
+// InitSchedule loads tasks and windows from db and starts checking them. tasks missed while the app was closed run right away
+func (scheduler *Scheduler) InitSchedule() error {
+	tasks, err := scheduler.db.GetScheduledTasks()
+	if err != nil {
+		return err
+	}
+	windows, err := scheduler.db.GetScheduleWindows()
+	if err != nil {
+		return err
+	}
+
+	scheduler.mutexForSchedule.Lock()
+	scheduler.tasks = tasks
+	scheduler.windows = windows
+	scheduler.mutexForSchedule.Unlock()
+
+	scheduler.check(scheduler.now())
+	go scheduler.run()
+	return nil
+}
+
+func (scheduler *Scheduler) Stop() {
+	close(scheduler.stop)
+}
+
+func (scheduler *Scheduler) run() {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-scheduler.stop:
+			return
+		case <-ticker.C:
+			scheduler.check(scheduler.now())
+		}
+	}
+}
+
+// check runs the tasks that are due and starts or stops the download queue for the windows
+func (scheduler *Scheduler) check(now time.Time) {
+	scheduler.mutexForSchedule.Lock()
+	dueTasks := make([]*types.ScheduledTask, 0)
+	for _, task := range scheduler.tasks {
+		if !task.FinishedAt.Valid && !task.RunAt.After(now) {
+			// finished before running so that another check doesn't run it again
+			task.FinishedAt.Time = now
+			task.FinishedAt.Valid = true
+			dueTasks = append(dueTasks, task)
+		}
+	}
+	isQueueActive := isInWindows(scheduler.windows, now)
+	nextStart := nextWindowStart(scheduler.windows, now)
+	scheduler.mutexForSchedule.Unlock()
+
+	for _, task := range dueTasks {
+		err := scheduler.runTask(task)
+
+		scheduler.mutexForSchedule.Lock()
+		if err != nil {
+			fmt.Printf("Error while running scheduled task %d : %s \n", task.Id, err)
+			task.Error = err.Error()
+		}
+		err = scheduler.db.UpdateScheduledTask(task)
+		scheduler.mutexForSchedule.Unlock()
+		if err != nil {
+			fmt.Printf("Error while updating scheduled task in db : %s \n", err)
+		}
+	}
+
+	if isQueueActive != scheduler.downloadEngine.IsQueueActive() {
+		if isQueueActive {
+			fmt.Printf("Starting download queue for schedule window \n")
+		} else {
+			fmt.Printf("Stopping download queue outside of schedule windows until %s \n", nextStart.Format(time.DateTime))
+		}
+		scheduler.downloadEngine.SetQueueActive(isQueueActive)
+	}
+}
+
+// isInWindows reports whether the queue can run now. queue always runs when there are no windows
+func isInWindows(windows []*types.ScheduleWindow, now time.Time) bool {
+	if len(windows) == 0 {
+		return true
+	}
+	for _, window := range windows {
+		if window.Contains(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextWindowStart returns the earliest start of the windows that is not before now. it is zero when there are no windows
+func nextWindowStart(windows []*types.ScheduleWindow, now time.Time) time.Time {
+	var nextStart time.Time
+	for _, window := range windows {
+		start, err := types.ParseClock(window.StartTime)
+		if err != nil {
+			continue
+		}
+		days := (window.Weekday - int(now.Weekday()) + 7) % 7
+		windowStart := time.Date(now.Year(), now.Month(), now.Day()+days, start/60, start%60, 0, 0, now.Location())
+		if windowStart.Before(now.Truncate(time.Minute)) {
+			// start of this week is passed
+			windowStart = windowStart.AddDate(0, 0, 7)
+		}
+		if nextStart.IsZero() || windowStart.Before(nextStart) {
+			nextStart = windowStart
+		}
+	}
+	return nextStart
+}
+
+func (scheduler *Scheduler) runTask(task *types.ScheduledTask) error {
+	if task.DownloadId != 0 {
+		switch task.Action {
+		case types.ScheduleActionStart.String():
+			return scheduler.downloadEngine.ForceStartDownload(task.DownloadId)
+		case types.ScheduleActionPause.String():
+			return scheduler.downloadEngine.PauseDownload(task.DownloadId)
+		}
+		return fmt.Errorf("unknown action %s", task.Action)
+	}
+
+	if scheduler.torrentEngine == nil {
+		return fmt.Errorf("torrent engine is not running")
+	}
+	switch task.Action {
+	case types.ScheduleActionStart.String():
+		return scheduler.torrentEngine.ResumeTorrent(task.TorrentInfohash)
+	case types.ScheduleActionPause.String():
+		return scheduler.torrentEngine.PauseTorrent(task.TorrentInfohash)
+	}
+	return fmt.Errorf("unknown action %s", task.Action)
+}
+
+func (scheduler *Scheduler) GetTasks() []*types.ScheduledTask {
+	scheduler.mutexForSchedule.Lock()
+	defer scheduler.mutexForSchedule.Unlock()
+
+	tasks := make([]*types.ScheduledTask, len(scheduler.tasks))
+	copy(tasks, scheduler.tasks)
+	return tasks
+}
+
+// AddTask saves the task. it runs on the next check when its time is already passed
+func (scheduler *Scheduler) AddTask(task *types.ScheduledTask) error {
+	if task.Action != types.ScheduleActionStart.String() && task.Action != types.ScheduleActionPause.String() {
+		return fmt.Errorf("unknown action %s", task.Action)
+	}
+	if (task.DownloadId == 0) == (task.TorrentInfohash == "") {
+		return fmt.Errorf("either a download or a torrent must be given")
+	}
+	if task.DownloadId != 0 {
+		_, err := scheduler.downloadEngine.GetDownload(task.DownloadId)
+		if err != nil {
+			return err
+		}
+	} else {
+		if scheduler.torrentEngine == nil {
+			return fmt.Errorf("torrent engine is not running")
+		}
+		_, err := scheduler.torrentEngine.GetTorrent(task.TorrentInfohash)
+		if err != nil {
+			return err
+		}
+	}
+
+	task.CreatedAt = scheduler.now()
+	id, err := scheduler.db.InsertScheduledTask(task)
+	if err != nil {
+		return err
+	}
+	task.Id = id
+
+	scheduler.mutexForSchedule.Lock()
+	scheduler.tasks = append(scheduler.tasks, task)
+	scheduler.mutexForSchedule.Unlock()
+	return nil
+}
+
+func (scheduler *Scheduler) DeleteTask(id int) error {
+	scheduler.mutexForSchedule.Lock()
+	defer scheduler.mutexForSchedule.Unlock()
+
+	for i, task := range scheduler.tasks {
+		if task.Id == id {
+			err := scheduler.db.DeleteScheduledTask(id)
+			if err != nil {
+				return err
+			}
+			scheduler.tasks = append(scheduler.tasks[:i], scheduler.tasks[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("scheduled task not found")
+}
+
+func (scheduler *Scheduler) GetWindows() []*types.ScheduleWindow {
+	scheduler.mutexForSchedule.Lock()
+	defer scheduler.mutexForSchedule.Unlock()
+
+	windows := make([]*types.ScheduleWindow, len(scheduler.windows))
+	copy(windows, scheduler.windows)
+	return windows
+}
+
+// AddWindow saves the window. download queue is started or stopped for it right away
+func (scheduler *Scheduler) AddWindow(window *types.ScheduleWindow) error {
+	if window.Weekday < 0 || window.Weekday > 6 {
+		return fmt.Errorf("weekday must be between 0 and 6")
+	}
+	_, err := types.ParseClock(window.StartTime)
+	if err != nil {
+		return err
+	}
+	_, err = types.ParseClock(window.EndTime)
+	if err != nil {
+		return err
+	}
+
+	window.CreatedAt = scheduler.now()
+	id, err := scheduler.db.InsertScheduleWindow(window)
+	if err != nil {
+		return err
+	}
+	window.Id = id
+
+	scheduler.mutexForSchedule.Lock()
+	scheduler.windows = append(scheduler.windows, window)
+	scheduler.mutexForSchedule.Unlock()
+
+	scheduler.check(scheduler.now())
+	return nil
+}
+
+func (scheduler *Scheduler) DeleteWindow(id int) error {
+	scheduler.mutexForSchedule.Lock()
+	found := false
+	for i, window := range scheduler.windows {
+		if window.Id == id {
+			err := scheduler.db.DeleteScheduleWindow(id)
+			if err != nil {
+				scheduler.mutexForSchedule.Unlock()
+				return err
+			}
+			scheduler.windows = append(scheduler.windows[:i], scheduler.windows[i+1:]...)
+			found = true
+			break
+		}
+	}
+	scheduler.mutexForSchedule.Unlock()
+	if !found {
+		return fmt.Errorf("schedule window not found")
+	}
+
+	scheduler.check(scheduler.now())
+	return nil
+}
@@ -0,0 +1,86 @@
+package scheduler
+
+import (
+	"downite/download/protocol/direct"
+	"downite/types"
+	"testing"
+	"time"
+)
+
+// 2024-06-01 is a saturday
+func saturday(hour int, minute int) time.Time {
+	return time.Date(2024, 6, 1, hour, minute, 0, 0, time.Local)
+}
+
+func TestNextWindowStart(t *testing.T) {
+	sundayMidnight := &types.ScheduleWindow{Weekday: 0, StartTime: "00:00", EndTime: "06:00"}
+	saturdayNight := &types.ScheduleWindow{Weekday: 6, StartTime: "23:00", EndTime: "01:00"}
+	wednesday := &types.ScheduleWindow{Weekday: 3, StartTime: "09:30", EndTime: "17:00"}
+
+	testCases := []struct {
+		name      string
+		windows   []*types.ScheduleWindow
+		now       time.Time
+		nextStart time.Time
+	}{
+		{"no windows", nil, saturday(12, 0), time.Time{}},
+		{"later on the same day", []*types.ScheduleWindow{saturdayNight}, saturday(12, 0), saturday(23, 0)},
+		{"at the start", []*types.ScheduleWindow{saturdayNight}, saturday(23, 0), saturday(23, 0)},
+		{"seconds after the start", []*types.ScheduleWindow{saturdayNight}, saturday(23, 0).Add(30 * time.Second), saturday(23, 0)},
+		{"start is passed this week", []*types.ScheduleWindow{saturdayNight}, saturday(23, 1), saturday(7*24+23, 0)},
+		{"at midnight of the next week", []*types.ScheduleWindow{sundayMidnight}, saturday(23, 59), saturday(24, 0)},
+		{"on a later day of the next week", []*types.ScheduleWindow{wednesday}, saturday(12, 0), saturday(4*24+9, 30)},
+		{"earliest of the windows", []*types.ScheduleWindow{wednesday, sundayMidnight, saturdayNight}, saturday(23, 30), saturday(24, 0)},
+		{"invalid windows are skipped", []*types.ScheduleWindow{{Weekday: 6, StartTime: "25:00", EndTime: "26:00"}, wednesday}, saturday(12, 0), saturday(4*24+9, 30)},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			nextStart := nextWindowStart(testCase.windows, testCase.now)
+			if !nextStart.Equal(testCase.nextStart) {
+				t.Errorf("expected %s, got %s", testCase.nextStart, nextStart)
+			}
+		})
+	}
+}
+
+func TestCheckStartsAndStopsQueue(t *testing.T) {
+	downloadEngine, err := direct.CreateDownloadClient(&direct.DownloadClientConfig{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := saturday(0, 0)
+	scheduler := &Scheduler{
+		downloadEngine: downloadEngine,
+		// saturday night window continues on sunday of the next week
+		windows: []*types.ScheduleWindow{
+			{Weekday: 6, StartTime: "23:00", EndTime: "01:00"},
+			{Weekday: 0, StartTime: "09:00", EndTime: "10:00"},
+		},
+		now: func() time.Time {
+			return now
+		},
+	}
+
+	// checks follow each other like the ticks of the scheduler
+	testCases := []struct {
+		name          string
+		now           time.Time
+		isQueueActive bool
+	}{
+		{"before the window", saturday(22, 59), false},
+		{"start of the window", saturday(23, 0), true},
+		{"before midnight", saturday(23, 59), true},
+		{"midnight", saturday(24, 0), true},
+		{"before the end", saturday(24, 59), true},
+		{"end of the window", saturday(25, 0), false},
+		{"start of the next window", saturday(33, 0), true},
+		{"end of the next window", saturday(34, 0), false},
+	}
+	for _, testCase := range testCases {
+		now = testCase.now
+		scheduler.check(scheduler.now())
+		if downloadEngine.IsQueueActive() != testCase.isQueueActive {
+			t.Errorf("%s : expected queue active to be %t", testCase.name, testCase.isQueueActive)
+		}
+	}
+}
@@ -39,14 +39,18 @@ func InitilizeSettingsSystem(db *db.Database, initialSettings *types.DowniteSett
 	settingsFile, err := os.OpenFile(path.Join(settingsFolderPath, "settings.json"), os.O_RDWR, 0644)
 	// if settings file doesn't exist create it
 	if err != nil {
-		if err.Error() == "no such file or directory" {
+		if os.IsNotExist(err) {
 			defaultSettings := GetDefaultSettings()
-			writeSettings(&defaultSettings)
+			err = writeSettings(&defaultSettings)
+			if err != nil {
+				return nil, err
+			}
 			system.Settings = &defaultSettings
 			return system, nil
 		}
 		return nil, err
 	}
+	defer settingsFile.Close()
 	// if settings file exists then read it
 
 	foundSettings, err := readSettings(settingsFile)
@@ -59,16 +63,38 @@ func InitilizeSettingsSystem(db *db.Database, initialSettings *types.DowniteSett
 
 func GetDefaultSettings() types.DowniteSettings {
 	return types.DowniteSettings{
-		Language:  "en",
-		SavePaths: []string{},
+		Language:               "en",
+		SavePaths:              []string{},
+		MaxConcurrentDownloads: 3,
+		Proxy: types.ProxySettings{
+			Type: "none",
+		},
+		Extraction: types.ExtractionSettings{
+			MaxRatio: 100,
+			MaxFiles: 100000,
+		},
+		// lower than the default part count so that parallel downloads from the same host share its connections
+		Hosts: types.HostSettings{
+			MaxConnectionsPerHost: 4,
+		},
+		Retry: types.RetrySettings{
+			RetryCount:    5,
+			RetryDelay:    1000,
+			MaxRetryDelay: 30000,
+		},
 	}
 }
 
 func writeSettings(settings *types.DowniteSettings) error {
-	settingsFile, err := os.OpenFile(path.Join(settingsFolderPath, "settings.json"), os.O_RDWR, 0644)
+	err := os.MkdirAll(settingsFolderPath, 0755)
 	if err != nil {
 		return err
 	}
+	settingsFile, err := os.OpenFile(path.Join(settingsFolderPath, "settings.json"), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer settingsFile.Close()
 	settingsJson, err := json.Marshal(settings)
 	if err != nil {
 		return err
@@ -87,6 +113,9 @@ func readSettings(settingsFile *os.File) (settings *types.DowniteSettings, err e
 		return nil, err
 	}
 
+	// start from defaults so that settings missing in the file keep their default values
+	defaultSettings := GetDefaultSettings()
+	settings = &defaultSettings
 	err = json.Unmarshal(settingsFileBytes, settings)
 	if err != nil {
 		return nil, err
@@ -105,3 +134,38 @@ func (system *DowniteSettingsSystem) SetLanguage(language string) error {
 	writeSettings(system.Settings)
 	return nil
 }
+
+func (system *DowniteSettingsSystem) SetMaxConcurrentDownloads(maxConcurrentDownloads int) error {
+	system.Settings.MaxConcurrentDownloads = maxConcurrentDownloads
+	return writeSettings(system.Settings)
+}
+
+func (system *DowniteSettingsSystem) SetSpeedLimit(speedLimit uint64) error {
+	system.Settings.SpeedLimit = speedLimit
+	return writeSettings(system.Settings)
+}
+
+func (system *DowniteSettingsSystem) SetProxy(proxySettings types.ProxySettings) error {
+	system.Settings.Proxy = proxySettings
+	return writeSettings(system.Settings)
+}
+
+func (system *DowniteSettingsSystem) SetExtraction(extractionSettings types.ExtractionSettings) error {
+	system.Settings.Extraction = extractionSettings
+	return writeSettings(system.Settings)
+}
+
+func (system *DowniteSettingsSystem) SetHosts(hostSettings types.HostSettings) error {
+	system.Settings.Hosts = hostSettings
+	return writeSettings(system.Settings)
+}
+
+func (system *DowniteSettingsSystem) SetRetry(retrySettings types.RetrySettings) error {
+	system.Settings.Retry = retrySettings
+	return writeSettings(system.Settings)
+}
+
+func (system *DowniteSettingsSystem) SetRestartChangedDownloads(restartChangedDownloads bool) error {
+	system.Settings.RestartChangedDownloads = restartChangedDownloads
+	return writeSettings(system.Settings)
+}
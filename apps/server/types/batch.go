@@ -0,0 +1,20 @@
+package types
+
+import "time"
+
+// DownloadBatch groups downloads added together like the entries of a playlist
+type DownloadBatch struct {
+	Id        int       `db:"id" json:"id"`
+	CreatedAt time.Time `db:"created_at" json:"createdAt"`
+	Name      string    `db:"name" json:"name"`
+	// url the downloads are added from like a playlist url
+	SourceUrl string `db:"source_url" json:"sourceUrl"`
+}
+
+// BatchImportResult is the result of a url imported into a batch
+type BatchImportResult struct {
+	Url        string `json:"url"`
+	Name       string `json:"name,omitempty"`
+	DownloadId int    `json:"downloadId,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
@@ -0,0 +1,85 @@
+package types
+
+import "time"
+
+type TransferKind int
+
+const (
+	TransferKindDownload TransferKind = iota
+	TransferKindTorrent
+)
+
+var TransferKindStringMap = map[TransferKind]string{
+	TransferKindDownload: "download",
+	TransferKindTorrent:  "torrent",
+}
+
+func (kind TransferKind) String() string {
+	return TransferKindStringMap[kind]
+}
+
+type CompletionAction int
+
+const (
+	CompletionActionMove CompletionAction = iota
+	CompletionActionCommand
+	CompletionActionWebhook
+)
+
+var CompletionActionStringMap = map[CompletionAction]string{
+	CompletionActionMove:    "move",
+	CompletionActionCommand: "command",
+	CompletionActionWebhook: "webhook",
+}
+
+func (action CompletionAction) String() string {
+	return CompletionActionStringMap[action]
+}
+
+// CompletionEvent is emitted by the engines when a download or a torrent is completed. it is sent to webhooks as json
+type CompletionEvent struct {
+	Kind string `json:"kind"`
+	// either download id or torrent infohash is set
+	DownloadId      int    `json:"downloadId"`
+	TorrentInfohash string `json:"torrentInfohash"`
+	Name            string `json:"name"`
+	SavePath        string `json:"savePath"`
+	// path of the downloaded file or the torrent's root file or directory
+	Path       string    `json:"path"`
+	Url        string    `json:"url"`
+	TotalSize  uint64    `json:"totalSize"`
+	FinishedAt time.Time `json:"finishedAt"`
+}
+
+// CompletionRule is an action that runs for every completed transfer of its kind
+type CompletionRule struct {
+	Id        int       `db:"id" json:"id"`
+	CreatedAt time.Time `db:"created_at" json:"createdAt"`
+	Name      string    `db:"name" json:"name"`
+	// kind of the transfers the rule runs for. all, download or torrent
+	Kind   string `db:"kind" json:"kind" enum:"all,download,torrent"`
+	Action string `db:"action" json:"action" enum:"move,command,webhook"`
+	// directory for move, executable for command and url for webhook
+	Target string `db:"target" json:"target"`
+	// arguments of the command. placeholders like {path} are replaced with the values of the transfer
+	Arguments StringList `db:"arguments" json:"arguments"`
+}
+
+// AppliesTo reports whether the rule runs for the event
+func (rule *CompletionRule) AppliesTo(event *CompletionEvent) bool {
+	return rule.Kind == "all" || rule.Kind == event.Kind
+}
+
+// CompletionResult is the result of a rule that ran for a transfer
+type CompletionResult struct {
+	Id              int       `db:"id" json:"id"`
+	CreatedAt       time.Time `db:"created_at" json:"createdAt"`
+	RuleId          int       `db:"rule_id" json:"ruleId"`
+	Action          string    `db:"action" json:"action"`
+	DownloadId      int       `db:"download_id" json:"downloadId"`
+	TorrentInfohash string    `db:"torrent_infohash" json:"torrentInfohash"`
+	IsSuccess       bool      `db:"is_success" json:"isSuccess"`
+	// output of the command or the response body of the webhook
+	Output string `db:"output" json:"output"`
+	Error  string `db:"error" json:"error"`
+}
@@ -2,6 +2,9 @@ package types
 
 import (
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
 	"time"
 )
 
@@ -13,6 +16,7 @@ const (
 	DownloadStatusCompleted
 	DownloadStatusError
 	DownloadStatusMetadata
+	DownloadStatusQueued
 )
 
 var DownloadStatusStringMap = map[DownloadStatus]string{
@@ -21,6 +25,7 @@ var DownloadStatusStringMap = map[DownloadStatus]string{
 	DownloadStatusCompleted:   "completed",
 	DownloadStatusError:       "error",
 	DownloadStatusMetadata:    "metadata",
+	DownloadStatusQueued:      "queued",
 }
 
 func (d DownloadStatus) String() string {
@@ -28,37 +33,117 @@ func (d DownloadStatus) String() string {
 }
 
 type Download struct {
-	Id                  int             `json:"id"`
-	CreatedAt           time.Time       `json:"createdAt" db:"created_at"`
-	StartedAt           sql.NullTime    `json:"startedAt" db:"started_at"`
-	TimeActive          time.Duration   `json:"timeActive" db:"time_active"`
-	FinishedAt          sql.NullTime    `json:"finishedAt" db:"finished_at"`
-	Status              string          `json:"status" enum:"paused,downloading,completed,error,metadata"`
-	Name                string          `json:"name"`
-	SavePath            string          `db:"save_path" json:"savePath"`
-	PartCount           int             `db:"part_count" json:"partCount"`
-	PartLength          uint64          `db:"part_length" json:"partLength"`
-	TotalSize           uint64          `db:"total_size" json:"totalSize"`
-	DownloadedBytes     uint64          `db:"downloaded_bytes" json:"downloadedBytes"`
-	BytesWritten        uint64          `db:"-" json:"-"`
-	DownloadSpeed       uint64          `db:"-" json:"downloadSpeed"`
-	Progress            float64         `json:"progress" db:"-"`
-	Parts               []*DownloadPart `json:"parts" db:"-"`
-	IsMultiPart         bool            `json:"isMultiPart" db:"is_multi_part"`
-	Url                 string          `json:"url"`
-	QueueNumber         int             `db:"queue_number" json:"queueNumber"`
-	CurrentWrittenBytes uint64          `db:"-" json:"-"`
-	Error               string          `db:"error" json:"error"`
+	Id                  int               `json:"id"`
+	CreatedAt           time.Time         `json:"createdAt" db:"created_at"`
+	StartedAt           sql.NullTime      `json:"startedAt" db:"started_at"`
+	TimeActive          time.Duration     `json:"timeActive" db:"time_active"`
+	FinishedAt          sql.NullTime      `json:"finishedAt" db:"finished_at"`
+	Status              string            `json:"status" enum:"paused,downloading,completed,error,metadata,queued"`
+	Name                string            `json:"name"`
+	SavePath            string            `db:"save_path" json:"savePath"`
+	PartCount           int               `db:"part_count" json:"partCount"`
+	PartLength          uint64            `db:"part_length" json:"partLength"`
+	TotalSize           uint64            `db:"total_size" json:"totalSize"`
+	DownloadedBytes     uint64            `db:"downloaded_bytes" json:"downloadedBytes"`
+	BytesWritten        uint64            `db:"-" json:"-"`
+	DownloadSpeed       uint64            `db:"-" json:"downloadSpeed"`
+	SmoothedSpeed       float64           `db:"-" json:"smoothedSpeed" doc:"Exponentially smoothed download speed in KB/s"`
+	Eta                 int64             `db:"-" json:"eta" doc:"Estimated seconds left. -1 when it can't be estimated"`
+	Progress            float64           `json:"progress" db:"-"`
+	Parts               []*DownloadPart   `json:"parts" db:"-"`
+	Mirrors             []*DownloadMirror `json:"mirrors" db:"-"`
+	IsMultiPart         bool              `json:"isMultiPart" db:"is_multi_part"`
+	Url                 string            `json:"url"`
+	QueueNumber         int               `db:"queue_number" json:"queueNumber"`
+	CurrentWrittenBytes uint64            `db:"-" json:"-"`
+	Error               string            `db:"error" json:"error"`
+	SpeedLimit          uint64            `db:"speed_limit" json:"speedLimit"`
+	ChecksumAlgorithm   string            `db:"checksum_algorithm" json:"checksumAlgorithm"`
+	Checksum            string            `db:"checksum" json:"checksum"`
+	Headers             DownloadHeaders   `db:"headers" json:"-"`
+	Proxy               string            `db:"proxy" json:"-"`
+	// user info of ftp urls like user:pass. it is kept out of the url so that the url can be shown
+	Credentials string `db:"credentials" json:"-"`
+	// headers given for the page of the download. pages are resolved again with them while headers only keep what the file host may get
+	PageHeaders DownloadHeaders `db:"page_headers" json:"-"`
+	// hls streams are downloaded segment by segment. parts are not used for them
+	IsHls                 bool `db:"is_hls" json:"isHls"`
+	SegmentCount          int  `db:"segment_count" json:"segmentCount"`
+	CompletedSegmentCount int  `db:"completed_segment_count" json:"completedSegmentCount"`
+	// every piece of the file is verified against its hash while downloading. parts with a bad piece are downloaded again
+	PieceHashAlgorithm string     `db:"piece_hash_algorithm" json:"pieceHashAlgorithm"`
+	PieceLength        uint64     `db:"piece_length" json:"pieceLength"`
+	PieceHashes        StringList `db:"piece_hashes" json:"-"`
+	// validators of the remote file. a resumed download only continues when the file on the server is still the same
+	ETag         string `db:"etag" json:"etag"`
+	LastModified string `db:"last_modified" json:"lastModified"`
+	// size of the file is not known until it is downloaded. total size is 0 until the download is completed and it uses a single connection
+	IsSizeUnknown bool `db:"is_size_unknown" json:"isSizeUnknown"`
+	// page the url is resolved from like a video page. url is resolved again from it when the download is restarted
+	PageUrl  string `db:"page_url" json:"pageUrl"`
+	FormatId string `db:"format_id" json:"formatId"`
+	// downloads added together like the entries of a playlist have the same batch. 0 means no batch
+	BatchId int `db:"batch_id" json:"batchId"`
+}
+
+// StringList is saved to db as json. piece hashes of downloads are kept in it
+type StringList []string
+
+func (list StringList) Value() (driver.Value, error) {
+	if len(list) == 0 {
+		return "", nil
+	}
+	value, err := json.Marshal(list)
+	if err != nil {
+		return nil, err
+	}
+	return string(value), nil
+}
+
+func (list *StringList) Scan(value any) error {
+	var data []byte
+	switch value := value.(type) {
+	case nil:
+		*list = nil
+		return nil
+	case string:
+		data = []byte(value)
+	case []byte:
+		data = value
+	default:
+		return fmt.Errorf("cannot scan %T into string list", value)
+	}
+	if len(data) == 0 {
+		*list = nil
+		return nil
+	}
+	return json.Unmarshal(data, list)
 }
 
 func (download *Download) Write(bytes []byte) (int, error) {
 	download.DownloadedBytes += uint64(len(bytes))
 	download.BytesWritten += uint64(len(bytes))
-	download.Progress = float64(download.DownloadedBytes) / float64(download.TotalSize) * 100
+	download.UpdateProgress()
 	// fmt.Printf("downloaded bytes : %d \n", download.DownloadedBytes)
 	return len(bytes), nil
 }
 
+// UpdateProgress calculates the progress from downloaded bytes. size of hls streams is not known until they are completed, their progress comes from segments
+func (download *Download) UpdateProgress() {
+	if download.IsHls {
+		if download.SegmentCount > 0 {
+			download.Progress = float64(download.CompletedSegmentCount) / float64(download.SegmentCount) * 100
+		}
+		return
+	}
+	// only downloaded bytes are reported while the size is not known
+	if download.TotalSize == 0 {
+		download.Progress = 0
+		return
+	}
+	download.Progress = float64(download.DownloadedBytes) / float64(download.TotalSize) * 100
+}
+
 type DownloadPart struct {
 	Id              int           `db:"id" json:"-"`
 	CreatedAt       time.Time     `db:"created_at" json:"createdAt"`
@@ -71,24 +156,78 @@ type DownloadPart struct {
 	EndByteIndex    uint64        `db:"end_byte_index" json:"endByteIndex"`
 	PartLength      uint64        `db:"part_length" json:"partLength"`
 	DownloadedBytes uint64        `db:"downloaded_bytes" json:"downloadedBytes"`
+	BytesWritten    uint64        `db:"-" json:"-"`
+	DownloadSpeed   uint64        `db:"-" json:"downloadSpeed"`
+	SmoothedSpeed   float64       `db:"-" json:"smoothedSpeed" doc:"Exponentially smoothed download speed in KB/s"`
+	Eta             int64         `db:"-" json:"eta" doc:"Estimated seconds left. -1 when it can't be estimated"`
 	Progress        float64       `db:"-" json:"progress"`
 	DownloadId      int           `json:"-" db:"download_id"`
 	Error           string        `db:"error" json:"error"`
+	MirrorUrl       string        `db:"-" json:"mirrorUrl"`
 }
 
 func (part *DownloadPart) Write(bytes []byte) (int, error) {
 	part.DownloadedBytes += uint64(len(bytes))
-	part.Progress = float64(part.DownloadedBytes) / float64(part.PartLength) * 100
+	part.BytesWritten += uint64(len(bytes))
+	if part.PartLength > 0 {
+		part.Progress = float64(part.DownloadedBytes) / float64(part.PartLength) * 100
+	}
 	// fmt.Printf("downloaded bytes for part number %d : | bytes : %d \n", part.PartIndex, part.DownloadedBytes)
 	return len(bytes), nil
 }
 
+// DownloadMirror is one of the urls a download is fetched from. stats are kept in memory only
+type DownloadMirror struct {
+	Id              int       `db:"id" json:"-"`
+	CreatedAt       time.Time `db:"created_at" json:"createdAt"`
+	DownloadId      int       `db:"download_id" json:"-"`
+	Url             string    `db:"url" json:"url"`
+	Credentials     string    `db:"credentials" json:"-"`
+	DownloadedBytes uint64    `db:"-" json:"downloadedBytes"`
+	BytesWritten    uint64    `db:"-" json:"-"`
+	DownloadSpeed   uint64    `db:"-" json:"downloadSpeed"`
+	ActivePartCount int       `db:"-" json:"activePartCount"`
+	ErrorCount      int       `db:"-" json:"errorCount"`
+	Error           string    `db:"-" json:"error"`
+	IsDisabled      bool      `db:"-" json:"isDisabled"`
+}
+
+func (mirror *DownloadMirror) Write(bytes []byte) (int, error) {
+	mirror.DownloadedBytes += uint64(len(bytes))
+	mirror.BytesWritten += uint64(len(bytes))
+	return len(bytes), nil
+}
+
 type DownloadMeta struct {
 	TotalSize          uint64 `json:"totalSize"`
 	Url                string `json:"url"`
 	FileName           string `json:"fileName"`
 	FileType           string `json:"fileType"`
 	IsRangeAllowed     bool   `json:"isRangeAllowed"`
+	IsSizeUnknown      bool   `json:"isSizeUnknown"`
+	ETag               string `json:"etag"`
+	LastModified       string `json:"lastModified"`
 	IsExist            bool   `json:"isExist"`
 	ExistingDownloadId int    `json:"existingDownloadId"`
+	DuplicateReason    string `json:"duplicateReason" doc:"What the existing download has in common. url, path or size with the same name"`
+	IsHls              bool   `json:"isHls"`
+	// variants of a master playlist. download one of them by its url. the first one is downloaded for the master playlist url
+	Variants     []HlsVariant `json:"variants" required:"false"`
+	SegmentCount int          `json:"segmentCount"`
+	// duration of the stream in seconds
+	Duration float64 `json:"duration"`
+	// resolver and page of urls like video pages. url is the resolved file url then
+	Resolver string `json:"resolver"`
+	PageUrl  string `json:"pageUrl"`
+	// playlist urls can be expanded into their entries
+	IsPlaylist bool `json:"isPlaylist"`
+}
+
+// HlsVariant is a stream of a master playlist
+type HlsVariant struct {
+	Url        string `json:"url"`
+	Bandwidth  uint32 `json:"bandwidth"`
+	Resolution string `json:"resolution"`
+	Codecs     string `json:"codecs"`
+	Name       string `json:"name"`
 }
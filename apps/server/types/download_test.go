@@ -0,0 +1,30 @@
+package types
+
+import "testing"
+
+func TestDownloadWrite(t *testing.T) {
+	testCases := []struct {
+		name      string
+		totalSize uint64
+		writes    []int
+		progress  float64
+	}{
+		{"known size", 200, []int{50, 50}, 50},
+		{"completed", 200, []int{150, 50}, 100},
+		{"unknown size", 0, []int{50, 50}, 0},
+	}
+	for _, testCase := range testCases {
+		download := &Download{TotalSize: testCase.totalSize}
+		written := 0
+		for _, size := range testCase.writes {
+			download.Write(make([]byte, size))
+			written += size
+		}
+		if download.DownloadedBytes != uint64(written) {
+			t.Errorf("%s : expected %d downloaded bytes, got %d", testCase.name, written, download.DownloadedBytes)
+		}
+		if download.Progress != testCase.progress {
+			t.Errorf("%s : expected progress %f, got %f", testCase.name, testCase.progress, download.Progress)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package types
+
+import (
+	"database/sql"
+	"time"
+)
+
+type ExtractionStatus int
+
+const (
+	ExtractionStatusExtracting ExtractionStatus = iota
+	ExtractionStatusCompleted
+	ExtractionStatusError
+)
+
+var ExtractionStatusStringMap = map[ExtractionStatus]string{
+	ExtractionStatusExtracting: "extracting",
+	ExtractionStatusCompleted:  "completed",
+	ExtractionStatusError:      "error",
+}
+
+func (status ExtractionStatus) String() string {
+	return ExtractionStatusStringMap[status]
+}
+
+// Extraction is an archive of a completed download or torrent that is extracted
+type Extraction struct {
+	Id        int       `db:"id" json:"id"`
+	CreatedAt time.Time `db:"created_at" json:"createdAt"`
+	// either download id or torrent infohash is set
+	DownloadId      int    `db:"download_id" json:"downloadId"`
+	TorrentInfohash string `db:"torrent_infohash" json:"torrentInfohash"`
+	ArchivePath     string `db:"archive_path" json:"archivePath"`
+	ExtractPath     string `db:"extract_path" json:"extractPath"`
+	Status          string `db:"status" json:"status" enum:"extracting,completed,error"`
+	// progress is calculated from the read bytes of the archive
+	Progress         float32      `db:"progress" json:"progress"`
+	IsArchiveDeleted bool         `db:"is_archive_deleted" json:"isArchiveDeleted"`
+	Error            string       `db:"error" json:"error"`
+	FinishedAt       sql.NullTime `db:"finished_at" json:"finishedAt"`
+}
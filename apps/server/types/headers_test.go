@@ -0,0 +1,33 @@
+package types
+
+import "testing"
+
+func TestDownloadHeadersForUrl(t *testing.T) {
+	headers := DownloadHeaders{
+		"Authorization": "Bearer token",
+		"Cookie":        "session=1",
+		"User-Agent":    "downite",
+	}
+	testCases := []struct {
+		name           string
+		url            string
+		hasCredentials bool
+	}{
+		{"same host", "https://files.example.com/other.zip", true},
+		{"same host with other case and port", "https://FILES.example.com:8443/file.zip", true},
+		{"subdomain", "https://cdn.files.example.com/file.zip", false},
+		{"other host", "https://mirror.example.org/file.zip", false},
+		{"invalid url", "://", false},
+	}
+	for _, testCase := range testCases {
+		filtered := headers.ForUrl("https://files.example.com/file.zip", testCase.url)
+		_, hasAuthorization := filtered["Authorization"]
+		_, hasCookie := filtered["Cookie"]
+		if hasAuthorization != testCase.hasCredentials || hasCookie != testCase.hasCredentials {
+			t.Errorf("%s : expected credentials to be sent %t, got %v", testCase.name, testCase.hasCredentials, filtered)
+		}
+		if filtered["User-Agent"] != "downite" {
+			t.Errorf("%s : other headers must be kept, got %v", testCase.name, filtered)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package types
+
+import "time"
+
+// SpeedSample is the speed of a transfer or of all transfers at a time. speeds are in KB/s
+type SpeedSample struct {
+	Time          time.Time `json:"time"`
+	DownloadSpeed float32   `json:"downloadSpeed"`
+	UploadSpeed   float32   `json:"uploadSpeed"`
+}
+
+type HistoryResolution int
+
+const (
+	HistoryResolutionSecond HistoryResolution = iota
+	HistoryResolutionMinute
+)
+
+var HistoryResolutionStringMap = map[HistoryResolution]string{
+	HistoryResolutionSecond: "second",
+	HistoryResolutionMinute: "minute",
+}
+
+func (resolution HistoryResolution) String() string {
+	return HistoryResolutionStringMap[resolution]
+}
+
+// DailyTransferTotal is the amount of bytes transferred by all downloads and torrents in a day
+type DailyTransferTotal struct {
+	// local date like 2024-01-31
+	Day             string `db:"day" json:"day"`
+	DownloadedBytes uint64 `db:"downloaded_bytes" json:"downloadedBytes"`
+	UploadedBytes   uint64 `db:"uploaded_bytes" json:"uploadedBytes"`
+}
+
+// TransferredBytes are the byte counters of a transfer. daily totals are built from their increase between samples
+type TransferredBytes struct {
+	DownloadedBytes uint64
+	UploadedBytes   uint64
+}
@@ -0,0 +1,21 @@
+package types
+
+// Playlist is a page with many files like a youtube playlist or channel
+type Playlist struct {
+	Title    string          `json:"title"`
+	Author   string          `json:"author"`
+	Url      string          `json:"url"`
+	Resolver string          `json:"resolver"`
+	Entries  []PlaylistEntry `json:"entries"`
+}
+
+type PlaylistEntry struct {
+	// position in the playlist starting from 1
+	Index  int    `json:"index"`
+	Title  string `json:"title"`
+	Author string `json:"author"`
+	// page url of the entry. it is resolved like any other page url
+	Url string `json:"url"`
+	// duration in seconds
+	Duration float64 `json:"duration"`
+}
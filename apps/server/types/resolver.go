@@ -0,0 +1,25 @@
+package types
+
+// ResolvedFile is a file a resolver found on a page
+type ResolvedFile struct {
+	Url  string `json:"url"`
+	Name string `json:"name"`
+	// headers needed to download the file. they are added to the headers of the download
+	Headers DownloadHeaders `json:"headers"`
+}
+
+// ResolvedFormat is a quality the file of a page can be downloaded in
+type ResolvedFormat struct {
+	Id       string `json:"id"`
+	MimeType string `json:"mimeType"`
+	// like 720p for videos and AUDIO_QUALITY_MEDIUM for audio
+	Quality  string `json:"quality"`
+	HasVideo bool   `json:"hasVideo"`
+	HasAudio bool   `json:"hasAudio"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	Fps      int    `json:"fps"`
+	Bitrate  int    `json:"bitrate"`
+	// 0 when the size is not known
+	Size uint64 `json:"size"`
+}
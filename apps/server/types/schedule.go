@@ -0,0 +1,76 @@
+package types
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type ScheduleAction int
+
+const (
+	ScheduleActionStart ScheduleAction = iota
+	ScheduleActionPause
+)
+
+var ScheduleActionStringMap = map[ScheduleAction]string{
+	ScheduleActionStart: "start",
+	ScheduleActionPause: "pause",
+}
+
+func (action ScheduleAction) String() string {
+	return ScheduleActionStringMap[action]
+}
+
+// ScheduledTask starts or pauses a download or a torrent once at RunAt
+type ScheduledTask struct {
+	Id        int       `db:"id" json:"id"`
+	CreatedAt time.Time `db:"created_at" json:"createdAt"`
+	RunAt     time.Time `db:"run_at" json:"runAt"`
+	Action    string    `db:"action" json:"action" enum:"start,pause"`
+	// either download id or torrent infohash is set
+	DownloadId      int          `db:"download_id" json:"downloadId"`
+	TorrentInfohash string       `db:"torrent_infohash" json:"torrentInfohash"`
+	FinishedAt      sql.NullTime `db:"finished_at" json:"finishedAt"`
+	Error           string       `db:"error" json:"error"`
+}
+
+// ScheduleWindow is a weekly time range in local time. when there are windows, the download queue only runs inside them
+type ScheduleWindow struct {
+	Id        int       `db:"id" json:"id"`
+	CreatedAt time.Time `db:"created_at" json:"createdAt"`
+	// 0 is sunday
+	Weekday int `db:"weekday" json:"weekday"`
+	// times are like 23:30. window ends on the next day when end time is not after start time
+	StartTime string `db:"start_time" json:"startTime"`
+	EndTime   string `db:"end_time" json:"endTime"`
+}
+
+// ParseClock returns the minutes after midnight of a time like 23:30
+func ParseClock(clock string) (int, error) {
+	var hour, minute int
+	_, err := fmt.Sscanf(clock, "%d:%d", &hour, &minute)
+	if err != nil || len(clock) != 5 || hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+		return 0, fmt.Errorf("invalid time %q. expected HH:MM", clock)
+	}
+	return hour*60 + minute, nil
+}
+
+// Contains reports whether the time is inside the window
+func (window *ScheduleWindow) Contains(now time.Time) bool {
+	start, err := ParseClock(window.StartTime)
+	if err != nil {
+		return false
+	}
+	end, err := ParseClock(window.EndTime)
+	if err != nil {
+		return false
+	}
+	minute := now.Hour()*60 + now.Minute()
+	weekday := int(now.Weekday())
+	if start < end {
+		return weekday == window.Weekday && minute >= start && minute < end
+	}
+	// window continues after midnight
+	return (weekday == window.Weekday && minute >= start) || (weekday == (window.Weekday+1)%7 && minute < end)
+}
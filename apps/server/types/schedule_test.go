@@ -0,0 +1,49 @@
+package types
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleWindowContains(t *testing.T) {
+	// 2024-06-03 is a monday
+	monday := func(hour int, minute int) time.Time {
+		return time.Date(2024, 6, 3, hour, minute, 0, 0, time.Local)
+	}
+	overnight := &ScheduleWindow{Weekday: 1, StartTime: "23:00", EndTime: "07:00"}
+	daytime := &ScheduleWindow{Weekday: 1, StartTime: "09:30", EndTime: "17:00"}
+
+	testCases := []struct {
+		name     string
+		window   *ScheduleWindow
+		now      time.Time
+		contains bool
+	}{
+		{"before overnight window", overnight, monday(22, 59), false},
+		{"start of overnight window", overnight, monday(23, 0), true},
+		{"overnight window on next day", overnight, monday(30, 59), true},
+		{"end of overnight window", overnight, monday(31, 0), false},
+		{"overnight window on previous day", overnight, monday(6, 0), false},
+		{"inside daytime window", daytime, monday(12, 0), true},
+		{"end of daytime window", daytime, monday(17, 0), false},
+		{"daytime window on another day", daytime, monday(36, 0), false},
+	}
+	for _, testCase := range testCases {
+		if testCase.window.Contains(testCase.now) != testCase.contains {
+			t.Errorf("%s : expected contains to be %t", testCase.name, testCase.contains)
+		}
+	}
+}
+
+func TestParseClock(t *testing.T) {
+	minutes, err := ParseClock("07:45")
+	if err != nil || minutes != 7*60+45 {
+		t.Errorf("expected 465 minutes, got %d %v", minutes, err)
+	}
+	for _, clock := range []string{"24:00", "7:45", "07:60", "aa:bb"} {
+		_, err := ParseClock(clock)
+		if err == nil {
+			t.Errorf("%s : expected error", clock)
+		}
+	}
+}
@@ -1,6 +1,85 @@
 package types
 
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+)
+
 type DowniteSettings struct {
-	Language  string   `json:"language"`
-	SavePaths []string `json:"savePaths"`
+	Language               string             `json:"language"`
+	SavePaths              []string           `json:"savePaths"`
+	MaxConcurrentDownloads int                `json:"maxConcurrentDownloads"`
+	SpeedLimit             uint64             `json:"speedLimit"`
+	Proxy                  ProxySettings      `json:"proxy"`
+	Extraction             ExtractionSettings `json:"extraction"`
+	Hosts                  HostSettings       `json:"hosts"`
+	Retry                  RetrySettings      `json:"retry"`
+	Completion             CompletionSettings `json:"completion"`
+	// downloads whose remote file changed start again from the beginning instead of failing
+	RestartChangedDownloads bool `json:"restartChangedDownloads"`
+}
+
+type ExtractionSettings struct {
+	IsEnabled bool `json:"isEnabled" doc:"Extract zip, tar, tar.gz, tar.bz2 and tar.xz archives of completed downloads and torrents"`
+	// archives are extracted next to themselves when it is empty
+	ExtractPath   string `json:"extractPath" required:"false" doc:"Folder archives are extracted into. Every archive gets its own folder in it. Archives are extracted next to themselves when it is empty"`
+	DeleteArchive bool   `json:"deleteArchive" required:"false" doc:"Delete archives of downloads after they are extracted. Archives of torrents are kept for seeding"`
+	// extraction stops when the files of an archive exceed these limits or the free space of the disk
+	MaxSize  uint64 `json:"maxSize" required:"false" doc:"Maximum size of the files of an archive in MB. 0 means only the free space of the disk limits it"`
+	MaxRatio int    `json:"maxRatio" required:"false" minimum:"0" doc:"Maximum ratio of the size of the files to the size of the archive. 0 means unlimited"`
+	MaxFiles int    `json:"maxFiles" required:"false" minimum:"0" doc:"Maximum number of files and folders in an archive. 0 means unlimited"`
+}
+
+// CompletionSettings is only read from the settings file so that commands cannot be allowed over the api
+type CompletionSettings struct {
+	AllowedCommands []string `json:"allowedCommands" required:"false" doc:"Executables command rules may run. Command rules with other targets are rejected"`
+}
+
+// RetrySettings apply to parts and hls segments. delay doubles on every retry up to max retry delay
+type RetrySettings struct {
+	RetryCount    int `json:"retryCount" minimum:"0" doc:"How many times a failed part is retried before the download fails"`
+	RetryDelay    int `json:"retryDelay" minimum:"0" doc:"Milliseconds to wait before the first retry. It doubles on every retry"`
+	MaxRetryDelay int `json:"maxRetryDelay" minimum:"0" doc:"Maximum milliseconds to wait between retries"`
+}
+
+type HostSettings struct {
+	MaxConnectionsPerHost int            `json:"maxConnectionsPerHost" minimum:"0" doc:"Maximum number of connections to a host across all downloads. 0 means unlimited"`
+	Overrides             []HostOverride `json:"overrides" required:"false" doc:"Limits of hosts that differ from the defaults"`
+}
+
+// HostOverride applies to the host and its subdomains. 0 values use the defaults
+type HostOverride struct {
+	Host           string `json:"host" minLength:"1" doc:"Host name like example.com. Subdomains like cdn.example.com share its limits"`
+	MaxConnections int    `json:"maxConnections" required:"false" minimum:"0" doc:"Maximum number of connections to the host. 0 uses the max connections per host"`
+	PartCount      int    `json:"partCount" required:"false" minimum:"0" maximum:"64" doc:"Number of parts of new downloads from the host. 0 uses the default part count"`
+	SpeedLimit     uint64 `json:"speedLimit" required:"false" doc:"Speed limit in KB/s shared by all downloads from the host. 0 means unlimited"`
+	RequestDelay   int    `json:"requestDelay" required:"false" minimum:"0" doc:"Milliseconds to wait between requests to the host"`
+}
+
+type ProxySettings struct {
+	Type     string `json:"type" enum:"none,http,https,socks5"`
+	Host     string `json:"host" required:"false"`
+	Port     int    `json:"port" required:"false" minimum:"0" maximum:"65535"`
+	Username string `json:"username" required:"false"`
+	Password string `json:"password" required:"false"`
+}
+
+// Url returns the proxy as url. it is empty when proxy is not used
+func (proxySettings *ProxySettings) Url() (string, error) {
+	if proxySettings.Type == "" || proxySettings.Type == "none" {
+		return "", nil
+	}
+	if proxySettings.Host == "" || proxySettings.Port == 0 {
+		return "", fmt.Errorf("proxy host and port are required")
+	}
+	proxyUrl := &url.URL{
+		Scheme: proxySettings.Type,
+		Host:   net.JoinHostPort(proxySettings.Host, strconv.Itoa(proxySettings.Port)),
+	}
+	if proxySettings.Username != "" {
+		proxyUrl.User = url.UserPassword(proxySettings.Username, proxySettings.Password)
+	}
+	return proxyUrl.String(), nil
 }